@@ -0,0 +1,387 @@
+package sunvoxgo
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ArpeggioPattern indicates the order an Arpeggiator plays a chord's notes in.
+type ArpeggioPattern int
+
+const (
+	ArpeggioUp     ArpeggioPattern = iota // One note per step, from the lowest to the highest
+	ArpeggioDown                          // One note per step, from the highest to the lowest
+	ArpeggioUpDown                        // One note per step, from the lowest to the highest and back down
+	ArpeggioRandom                        // One randomly chosen note per step
+	ArpeggioStrum                         // All of the notes each step, one after another from the lowest (see Arpeggiator.SetStrumDelay())
+	ArpeggioBlock                         // All of the notes each step, at once
+)
+
+// Arpeggiator plays chords on a SunvoxModule in time with its SunvoxChannel, either arpeggiated (one note per step) or
+// as a whole each step. Its steps are counted in lines from the beginning of the song, so while the channel is playing,
+// they follow its playhead; while it isn't, the Arpeggiator keeps going by itself (see SetTempo()).
+//
+// The chord can be changed at any time (for example, to follow the player's state); the notes of the previous chord
+// are released immediately, and the new chord starts playing on the next step.
+//
+// Like a StepSequencer's steps, notes are sent ahead of time with timestamps through an EventScheduler, so they play
+// precisely regardless of how often the engine is updated. Other changes (like to the pattern or the gate) take effect
+// for steps that haven't been sent yet.
+type Arpeggiator struct {
+	Instrument *Instrument // The Instrument used to play notes
+
+	mutex      sync.Mutex
+	chord      *Chord
+	notes      []Note
+	pattern    ArpeggioPattern
+	voicing    Voicing
+	stepLength float64
+	gate       float64
+	velocity   int
+	strumDelay time.Duration
+	running    bool
+
+	clock     lineClock
+	scheduler *EventScheduler
+	until     float64 // The position (in lines) up to which steps have been scheduled
+	stepCount int     // The number of steps played with the current chord
+	voices    []*Voice
+	held      []*Voice // Voices held until the next step, which ends them when it's scheduled
+}
+
+// NewArpeggiator creates a new Arpeggiator to play chords on the given module. By default, it plays ArpeggioUp with one
+// note per line, holding each note until the next step.
+func NewArpeggiator(module *SunvoxModule) *Arpeggiator {
+	return &Arpeggiator{
+		Instrument: NewInstrument(module),
+		stepLength: 1,
+		strumDelay: time.Millisecond * 20,
+		scheduler:  NewEventScheduler(module.Channel),
+	}
+}
+
+// SetChord sets the chord the Arpeggiator plays, releasing the notes of the previous one.
+func (a *Arpeggiator) SetChord(chord Chord) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.release()
+	a.chord = &chord
+	a.notes = chord.Notes(a.voicing)
+	a.stepCount = 0
+}
+
+// ClearChord stops the Arpeggiator from playing any chord (releasing the notes of the current one) until another is set.
+func (a *Arpeggiator) ClearChord() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.release()
+	a.chord = nil
+	a.notes = nil
+}
+
+// Chord returns the chord the Arpeggiator is playing, and whether it has one.
+func (a *Arpeggiator) Chord() (Chord, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.chord == nil {
+		return Chord{}, false
+	}
+	return *a.chord, true
+}
+
+// SetPattern sets the order the Arpeggiator plays the chord's notes in.
+func (a *Arpeggiator) SetPattern(pattern ArpeggioPattern) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.pattern = pattern
+	a.stepCount = 0
+}
+
+// SetVoicing sets how the chord's notes are arranged (see Voicing).
+func (a *Arpeggiator) SetVoicing(voicing Voicing) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.voicing = voicing
+	if a.chord != nil {
+		a.notes = a.chord.Notes(voicing)
+	}
+}
+
+// SetStepLength sets how many lines each step lasts. If stepLength is less than or equal to 0, it's set to 1.
+func (a *Arpeggiator) SetStepLength(stepLength float64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if stepLength <= 0 {
+		stepLength = 1
+	}
+	a.stepLength = stepLength
+}
+
+// SetGate sets how long notes are held, as a fraction of a step (from 0 to 1). If it's 0 (the default), notes are
+// held until the next step.
+func (a *Arpeggiator) SetGate(gate float64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.gate = min(max(gate, 0), 1)
+}
+
+// SetVelocity sets the velocity notes are played with (from 1 to 129); 0 (the default) uses the default velocity.
+func (a *Arpeggiator) SetVelocity(velocity int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.velocity = velocity
+}
+
+// SetStrumDelay sets the time between each note of a strum, for ArpeggioStrum. By default, it's 20 milliseconds.
+func (a *Arpeggiator) SetStrumDelay(delay time.Duration) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.strumDelay = max(delay, 0)
+}
+
+// SetTempo sets the tempo the Arpeggiator runs at while its SunvoxChannel isn't playing. If bpm or tpl are less than
+// or equal to 0 (the default), the channel's BPM or TPL are used.
+func (a *Arpeggiator) SetTempo(bpm float64, tpl int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock.bpm = bpm
+	a.clock.tpl = tpl
+}
+
+// Start starts the Arpeggiator. It plays its first step on the next step boundary (or immediately, if it's on one).
+func (a *Arpeggiator) Start() {
+
+	a.mutex.Lock()
+	if a.running {
+		a.mutex.Unlock()
+		return
+	}
+	a.running = true
+	a.clock.reset(time.Now())
+	a.until = math.Inf(-1)
+	a.stepCount = 0
+	a.mutex.Unlock()
+
+	engine.addTask(a)
+
+	a.update(time.Now())
+
+}
+
+// Stop stops the Arpeggiator, releasing its notes.
+func (a *Arpeggiator) Stop() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.running {
+		return
+	}
+	a.running = false
+	a.release()
+	engine.removeTask(a)
+	a.scheduler.Close()
+}
+
+// IsRunning returns if the Arpeggiator is running.
+func (a *Arpeggiator) IsRunning() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.running
+}
+
+func (a *Arpeggiator) step(dt float32) bool {
+	a.update(time.Now())
+	return false
+}
+
+// update schedules the steps that will be reached before the next update.
+func (a *Arpeggiator) update(now time.Time) {
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.running {
+		return
+	}
+
+	channel := a.Instrument.Module.Channel
+
+	// Voices that have finished are forgotten
+	a.voices = slices.DeleteFunc(a.voices, func(voice *Voice) bool { return !voice.IsActive() })
+
+	wasSynced := a.clock.synced
+	lastLine := a.clock.line
+
+	line, lpm, ok := a.clock.update(channel, now)
+	if !ok {
+		return
+	}
+
+	latency := time.Duration(0)
+
+	if a.clock.synced {
+
+		// The song's audio is heard later than it's rendered, so notes are delayed to match
+		latency = engine.Latency()
+
+		// If the playhead jumped (from looping or seeking), the notes scheduled for where it was are released
+		if !wasSynced || line < lastLine-1 || line > a.until+a.stepLength {
+			a.release()
+		}
+
+	}
+
+	if math.IsInf(a.until, -1) {
+		// Steps that start right at the current position are played as well; starting partway through a step waits for
+		// the next one
+		a.until = line - 1e-6
+	}
+
+	// Steps are scheduled as far ahead as the scheduler sends them, so that changes take effect as late as possible
+	end := line + a.scheduler.Horizon.Minutes()*lpm
+
+	// Steps past the end of the song or the active loop region won't be reached while playing
+	if a.clock.synced {
+		end = min(end, float64(channel.noteScanLimit())-1e-6)
+	}
+
+	if end <= a.until {
+		return
+	}
+
+	secondsPerLine := 60 / lpm
+	stepDuration := time.Duration(a.stepLength * secondsPerLine * float64(time.Second))
+
+	for index := max(int(math.Floor(a.until/a.stepLength)), 0); index <= int(math.Ceil(end/a.stepLength)); index++ {
+
+		stepLine := float64(index) * a.stepLength
+		if stepLine <= a.until || stepLine > end {
+			continue
+		}
+
+		at := now.Add(time.Duration((stepLine-line)*secondsPerLine*float64(time.Second)) + latency)
+
+		a.playStep(at, stepDuration)
+
+	}
+
+	a.until = end
+
+}
+
+// playStep schedules the notes of the next step to be heard at the given time, each held until the gate has passed (or
+// until the next step). The Arpeggiator's mutex must be held.
+func (a *Arpeggiator) playStep(at time.Time, stepDuration time.Duration) {
+
+	a.endHeld(at)
+
+	count := len(a.notes)
+	if count == 0 {
+		return
+	}
+
+	stepCount := a.stepCount
+	a.stepCount++
+
+	// Notes held for the whole step are ended by the next step instead, so that they end exactly when it starts
+	off := time.Time{}
+	if a.gate > 0 && a.gate < 1 {
+		off = at.Add(time.Duration(a.gate * float64(stepDuration)))
+	}
+
+	switch a.pattern {
+
+	case ArpeggioDown:
+		a.play(a.notes[count-1-stepCount%count], at, off)
+
+	case ArpeggioUpDown:
+		index := 0
+		if count > 1 {
+			// The top and bottom notes aren't repeated when turning around
+			period := count*2 - 2
+			index = stepCount % period
+			if index >= count {
+				index = period - index
+			}
+		}
+		a.play(a.notes[index], at, off)
+
+	case ArpeggioRandom:
+		a.play(a.notes[rand.IntN(count)], at, off)
+
+	case ArpeggioStrum:
+		for i, note := range a.notes {
+			a.play(note, at.Add(a.strumDelay*time.Duration(i)), off)
+		}
+
+	case ArpeggioBlock:
+		for _, note := range a.notes {
+			a.play(note, at, off)
+		}
+
+	default:
+		a.play(a.notes[stepCount%count], at, off)
+
+	}
+
+}
+
+// play schedules the given note to be heard from the given time until the given time; if off is zero, the note is held
+// until the next step. Notes that would end before they start (like the last notes of a long strum) aren't played.
+// The Arpeggiator's mutex must be held.
+func (a *Arpeggiator) play(note Note, at, off time.Time) {
+
+	if !off.IsZero() && !at.Before(off) {
+		return
+	}
+
+	voice, err := a.Instrument.noteOnAt(a.scheduler, at, note, a.velocity)
+	if err != nil {
+		return
+	}
+
+	if off.IsZero() {
+		a.held = append(a.held, voice)
+	} else {
+		channel := a.Instrument.Module.Channel
+		channel.voiceMutex.Lock()
+		voice.offAt(a.scheduler, off)
+		channel.voiceMutex.Unlock()
+	}
+
+	a.voices = append(a.voices, voice)
+
+}
+
+// endHeld schedules the voices held until the next step to end at the given time, when it starts; those that would start
+// only after that (like the last notes of a long strum) are canceled. The Arpeggiator's mutex must be held.
+func (a *Arpeggiator) endHeld(at time.Time) {
+
+	channel := a.Instrument.Module.Channel
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+
+	for _, voice := range a.held {
+		if voice.started.Before(at) {
+			voice.offAt(a.scheduler, at)
+		} else {
+			voice.off()
+		}
+	}
+
+	a.held = a.held[:0]
+
+}
+
+// release turns off the notes the Arpeggiator is playing, canceling those that haven't been sent yet, so that steps are
+// scheduled again from the current position. The Arpeggiator's mutex must be held.
+func (a *Arpeggiator) release() {
+	for _, voice := range a.voices {
+		voice.Off()
+	}
+	a.voices = a.voices[:0]
+	a.held = a.held[:0]
+	a.until = math.Inf(-1)
+}
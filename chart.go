@@ -0,0 +1,341 @@
+package sunvoxgo
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ChartNote represents a note in a Chart.
+type ChartNote struct {
+	Time     time.Duration // When the note is played, from the beginning of the song
+	Duration time.Duration // How long the note lasts; see SunvoxChannel.ExtractChart() for how this is determined
+
+	Line        int    // The line of the song the note is on
+	Lines       int    // How many lines the note lasts
+	Pattern     int    // The index of the pattern the note is in
+	PatternName string // The name of the pattern the note is in
+	Track       int    // The track (column) of the pattern the note is on
+
+	Note            Note   // The note's value
+	Velocity        uint8  // The note's velocity; 0 means the default velocity
+	Module          int    // The index of the module the note plays, or -1 if the note has no module set
+	ModuleName      string // The name of the module the note plays
+	Controller      uint16 // The controller / effect column of the note
+	ControllerValue uint16 // The controller / effect value of the note
+}
+
+// chartNoteJSON is how a ChartNote is represented in JSON; times are given in seconds, to be easily read by other tools.
+type chartNoteJSON struct {
+	Time            float64 `json:"time"`
+	Duration        float64 `json:"duration"`
+	Line            int     `json:"line"`
+	Lines           int     `json:"lines"`
+	Pattern         int     `json:"pattern"`
+	PatternName     string  `json:"patternName,omitempty"`
+	Track           int     `json:"track"`
+	Note            uint8   `json:"note"`
+	Velocity        uint8   `json:"velocity"`
+	Module          int     `json:"module"`
+	ModuleName      string  `json:"moduleName,omitempty"`
+	Controller      uint16  `json:"controller,omitempty"`
+	ControllerValue uint16  `json:"controllerValue,omitempty"`
+}
+
+// MarshalJSON returns the ChartNote in JSON format, with its time and duration in seconds.
+func (n ChartNote) MarshalJSON() ([]byte, error) {
+	return json.Marshal(chartNoteJSON{
+		Time:            n.Time.Seconds(),
+		Duration:        n.Duration.Seconds(),
+		Line:            n.Line,
+		Lines:           n.Lines,
+		Pattern:         n.Pattern,
+		PatternName:     n.PatternName,
+		Track:           n.Track,
+		Note:            uint8(n.Note),
+		Velocity:        n.Velocity,
+		Module:          n.Module,
+		ModuleName:      n.ModuleName,
+		Controller:      n.Controller,
+		ControllerValue: n.ControllerValue,
+	})
+}
+
+// UnmarshalJSON reads the ChartNote from JSON in the format written by MarshalJSON().
+func (n *ChartNote) UnmarshalJSON(data []byte) error {
+
+	j := chartNoteJSON{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*n = ChartNote{
+		Time:            time.Duration(j.Time * float64(time.Second)),
+		Duration:        time.Duration(j.Duration * float64(time.Second)),
+		Line:            j.Line,
+		Lines:           j.Lines,
+		Pattern:         j.Pattern,
+		PatternName:     j.PatternName,
+		Track:           j.Track,
+		Note:            Note(j.Note),
+		Velocity:        j.Velocity,
+		Module:          j.Module,
+		ModuleName:      j.ModuleName,
+		Controller:      j.Controller,
+		ControllerValue: j.ControllerValue,
+	}
+
+	return nil
+
+}
+
+// Chart represents all of the notes in a project, with exact times; see SunvoxChannel.ExtractChart().
+// A Chart can be saved and loaded with the encoding/json package.
+type Chart struct {
+	Name   string        // The name of the project
+	BPM    int           // The project's BPM at the beginning of the song
+	TPL    int           // The project's TPL at the beginning of the song
+	Lines  int           // The length of the project in lines
+	Length time.Duration // The length of the project
+	Notes  []ChartNote   // The notes in the project, ordered by time (and then by pattern and track)
+}
+
+// chartJSON is how a Chart is represented in JSON; the length is given in seconds, like the times of its notes.
+type chartJSON struct {
+	Name   string      `json:"name"`
+	BPM    int         `json:"bpm"`
+	TPL    int         `json:"tpl"`
+	Lines  int         `json:"lines"`
+	Length float64     `json:"length"`
+	Notes  []ChartNote `json:"notes"`
+}
+
+// MarshalJSON returns the Chart in JSON format, with times in seconds.
+func (c Chart) MarshalJSON() ([]byte, error) {
+	return json.Marshal(chartJSON{
+		Name:   c.Name,
+		BPM:    c.BPM,
+		TPL:    c.TPL,
+		Lines:  c.Lines,
+		Length: c.Length.Seconds(),
+		Notes:  c.Notes,
+	})
+}
+
+// UnmarshalJSON reads the Chart from JSON in the format written by MarshalJSON().
+func (c *Chart) UnmarshalJSON(data []byte) error {
+
+	j := chartJSON{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*c = Chart{
+		Name:   j.Name,
+		BPM:    j.BPM,
+		TPL:    j.TPL,
+		Lines:  j.Lines,
+		Length: time.Duration(j.Length * float64(time.Second)),
+		Notes:  j.Notes,
+	}
+
+	return nil
+
+}
+
+// NotesBetween returns the notes in the Chart that are played from the start time up to (but not including) the end time.
+func (c *Chart) NotesBetween(start, end time.Duration) []ChartNote {
+	compare := func(n ChartNote, t time.Duration) int { return cmp.Compare(n.Time, t) }
+	from, _ := slices.BinarySearchFunc(c.Notes, start, compare)
+	to, _ := slices.BinarySearchFunc(c.Notes, end, compare)
+	return c.Notes[from:max(from, to)]
+}
+
+// ExtractChart analyzes the project loaded in the SunvoxChannel and returns a Chart of all of the notes in it, along with
+// their exact times. This is useful to precompute charts for rhythm games, for example. Playback doesn't need to be
+// running (and isn't affected).
+//
+// Patterns are walked according to their X positions, so clones of a pattern each contribute their notes; muted patterns
+// and parts of patterns outside of the song (before line 0 or after its end) are skipped. Times take tempo changes into
+// account through Sunvox's time map.
+//
+// Note commands (like NoteCommandNoteOff) aren't included as notes; a note lasts until the next note or the next note off
+// (or note command that silences it) on the same track of the same pattern, or until the end of the pattern.
+func (s *SunvoxChannel) ExtractChart() (*Chart, error) {
+
+	lineCount := s.LengthInLines()
+	if lineCount <= 0 {
+		return nil, errors.New(fmt.Sprintf("error extracting chart from channel %d; no project with any length is loaded", s.Index))
+	}
+
+	sampleRate := float64(getSampleRate())
+	if sampleRate <= 0 {
+		return nil, errors.New(fmt.Sprintf("error extracting chart from channel %d; the sample rate couldn't be read", s.Index))
+	}
+
+	frames := make([]uint32, lineCount)
+	if res := getTimeMap(s.Index, 0, lineCount, &frames[0], timeMapFrameCount); res != 0 {
+		return nil, errors.New(fmt.Sprintf("error extracting chart from channel %d; couldn't get time map; error code %d", s.Index, res))
+	}
+
+	totalFrames := getLengthFrames(s.Index)
+
+	speed := uint32(0)
+	getTimeMap(s.Index, 0, 1, &speed, timeMapSpeed)
+
+	lineTime := func(line int) time.Duration {
+		frame := totalFrames
+		if line < lineCount {
+			frame = frames[line]
+		}
+		return time.Duration(float64(frame) / sampleRate * float64(time.Second))
+	}
+
+	chart := &Chart{
+		Name:   s.ProjectName(),
+		BPM:    int(speed & 0xFFFF),
+		TPL:    int(speed >> 16),
+		Lines:  lineCount,
+		Length: lineTime(lineCount),
+		Notes:  []ChartNote{},
+	}
+
+	moduleNames := map[int]string{}
+
+	// The cells of the patterns are copied while the channel is locked, so they can't change while they're read; the chart
+	// is built from the copies afterwards
+	type chartPattern struct {
+		index      int
+		name       string
+		x          int
+		lines      int
+		trackCount int
+		cells      []SunvoxPatternNoteData
+	}
+
+	patterns := []chartPattern{}
+
+	if err := s.Lock(); err != nil {
+		return nil, err
+	}
+
+	for pattern := range s.Patterns() {
+
+		x := pattern.X()
+
+		// The line count is read directly, as LineCount() can pause the engine, which can't be done while locked
+		patternLines := int(getPatternLineCount(s.Index, pattern.Index))
+		muted := setPatternMute(int32(s.Index), int32(pattern.Index), -1) == 1
+		if patternLines <= 0 || x+patternLines <= 0 || x >= lineCount || muted {
+			continue
+		}
+
+		trackCount, err := pattern.TrackCount()
+		if err != nil {
+			s.Unlock()
+			return nil, err
+		}
+
+		data, err := pattern.data(patternLines, trackCount)
+		if err != nil {
+			s.Unlock()
+			return nil, err
+		}
+
+		patterns = append(patterns, chartPattern{
+			index:      pattern.Index,
+			name:       pattern.Name(),
+			x:          x,
+			lines:      patternLines,
+			trackCount: trackCount,
+			cells:      slices.Clone(data.Data),
+		})
+
+	}
+
+	s.Unlock()
+
+	for _, pattern := range patterns {
+
+		x := pattern.x
+		trackCount := pattern.trackCount
+		end := min(x+pattern.lines, lineCount)
+
+		for track := range trackCount {
+
+			// The note currently playing on the track, which lasts until the next note or note off
+			playing := -1
+
+			finish := func(line int) {
+				if playing < 0 {
+					return
+				}
+				note := &chart.Notes[playing]
+				note.Lines = line - note.Line
+				note.Duration = lineTime(line) - note.Time
+				playing = -1
+			}
+
+			for line := max(x, 0); line < end; line++ {
+
+				noteData := pattern.cells[track+(line-x)*trackCount]
+
+				switch {
+
+				case noteData.Note == 0:
+					continue
+
+				case noteData.Note >= NoteCommandNoteOff:
+					switch noteData.Note {
+					case NoteCommandNoteOff, NoteCommandAllNotesOff, NoteCommandCleanSynths, NoteCommandStop, NoteCommandCleanModule:
+						finish(line)
+					}
+
+				default:
+
+					finish(line)
+
+					module := int(noteData.Module) - 1
+					if _, ok := moduleNames[module]; !ok {
+						if m := s.ModuleByIndex(module); m != nil {
+							moduleNames[module] = m.Name()
+						} else {
+							moduleNames[module] = ""
+						}
+					}
+
+					chart.Notes = append(chart.Notes, ChartNote{
+						Time:            lineTime(line),
+						Line:            line,
+						Pattern:         pattern.index,
+						PatternName:     pattern.name,
+						Track:           track,
+						Note:            Note(noteData.Note),
+						Velocity:        noteData.Velocity,
+						Module:          max(module, -1),
+						ModuleName:      moduleNames[module],
+						Controller:      noteData.Controller,
+						ControllerValue: noteData.ControllerValue,
+					})
+
+					playing = len(chart.Notes) - 1
+
+				}
+
+			}
+
+			finish(end)
+
+		}
+
+	}
+
+	// Patterns are walked in order, so a stable sort keeps notes at the same time in pattern and track order
+	slices.SortStableFunc(chart.Notes, func(a, b ChartNote) int { return a.Line - b.Line })
+
+	return chart, nil
+
+}
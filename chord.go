@@ -0,0 +1,105 @@
+package sunvoxgo
+
+import "slices"
+
+// ChordQuality represents the kind of a chord as the intervals of its notes from its root.
+type ChordQuality []Interval
+
+var (
+	ChordMajor          = ChordQuality{0, 4, 7}
+	ChordMinor          = ChordQuality{0, 3, 7}
+	ChordDiminished     = ChordQuality{0, 3, 6}
+	ChordAugmented      = ChordQuality{0, 4, 8}
+	ChordSus2           = ChordQuality{0, 2, 7}
+	ChordSus4           = ChordQuality{0, 5, 7}
+	ChordMajor7         = ChordQuality{0, 4, 7, 11}
+	ChordMinor7         = ChordQuality{0, 3, 7, 10}
+	ChordDominant7      = ChordQuality{0, 4, 7, 10}
+	ChordHalfDiminished = ChordQuality{0, 3, 6, 10}
+	ChordDiminished7    = ChordQuality{0, 3, 6, 9}
+	ChordPower          = ChordQuality{0, 7}
+)
+
+// Voicing indicates how a Chord's notes are arranged.
+type Voicing struct {
+	Inversion int // How many of the chord's lowest notes are moved up an octave (so 1 is the first inversion)
+	Octaves   int // How many octaves the chord's notes are spread over, repeating them an octave higher each time; 0 is treated as 1
+	Transpose int // How many semitones all of the notes are transposed by
+}
+
+// Chord represents a set of notes played together, as a root note and the intervals of the notes from it.
+type Chord struct {
+	Root      Note       // The root note
+	Intervals []Interval // The intervals of the chord's notes from the root
+}
+
+// NewChord returns a Chord with the given root note and quality (like ChordMajor).
+func NewChord(root Note, quality ChordQuality) Chord {
+	return Chord{
+		Root:      root,
+		Intervals: slices.Clone(quality),
+	}
+}
+
+// NewChordFromDegrees returns a Chord built from the given degrees of a scale, counting from 1 (so degrees 1, 3 and 5 of
+// a major scale make a major triad). Degrees past the end of the scale continue into the next octave (so degree 9 is
+// degree 2 an octave higher). See also Scale.Chord().
+func NewChordFromDegrees(root Note, scale Scale, degrees ...int) Chord {
+
+	chord := Chord{Root: root}
+
+	if len(scale) == 0 {
+		return chord
+	}
+
+	for _, degree := range degrees {
+		chord.Intervals = append(chord.Intervals, scale.degreeInterval(degree))
+	}
+
+	return chord
+
+}
+
+// Notes returns the notes of the Chord arranged according to the given Voicing, from lowest to highest.
+// Notes outside of the range of playable notes (1 to 127) are left out.
+func (c Chord) Notes(voicing Voicing) []Note {
+
+	intervals := slices.Clone(c.Intervals)
+	slices.Sort(intervals)
+
+	// Each inversion moves the lowest note up an octave
+	for range max(voicing.Inversion, 0) {
+		if len(intervals) == 0 {
+			break
+		}
+		intervals = append(intervals[1:], intervals[0]+12)
+	}
+
+	notes := []Note{}
+
+	if !c.Root.IsValid() {
+		return notes
+	}
+
+	for octave := range max(voicing.Octaves, 1) {
+		for _, interval := range intervals {
+			if note, ok := c.Root.Transpose(interval + Interval(octave*12+voicing.Transpose)); ok {
+				notes = append(notes, note)
+			}
+		}
+	}
+
+	slices.Sort(notes)
+
+	return slices.Compact(notes)
+
+}
+
+// floorDiv divides a by b, rounding down (rather than towards 0, like Go's division).
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,157 @@
+package sunvoxgo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChordNotes(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		chord   Chord
+		voicing Voicing
+		want    []Note
+	}{
+		{name: "root position", chord: NewChord(NoteC5, ChordMajor), want: []Note{61, 65, 68}},
+		{name: "first inversion", chord: NewChord(NoteC5, ChordMajor), voicing: Voicing{Inversion: 1}, want: []Note{65, 68, 73}},
+		{name: "second inversion", chord: NewChord(NoteC5, ChordMajor), voicing: Voicing{Inversion: 2}, want: []Note{68, 73, 77}},
+		{name: "inverted past the chord", chord: NewChord(NoteC5, ChordMajor), voicing: Voicing{Inversion: 3}, want: []Note{73, 77, 80}},
+		{name: "negative inversion", chord: NewChord(NoteC5, ChordMajor), voicing: Voicing{Inversion: -1}, want: []Note{61, 65, 68}},
+		{name: "two octaves", chord: NewChord(NoteC5, ChordMinor), voicing: Voicing{Octaves: 2}, want: []Note{61, 64, 68, 73, 76, 80}},
+		{name: "transposed", chord: NewChord(NoteC5, ChordMajor7), voicing: Voicing{Transpose: -12}, want: []Note{49, 53, 56, 60}},
+		{name: "unsorted intervals", chord: Chord{Root: NoteC5, Intervals: []Interval{7, 0, 4}}, want: []Note{61, 65, 68}},
+		{name: "duplicates removed", chord: Chord{Root: NoteC5, Intervals: []Interval{0, 12}}, voicing: Voicing{Octaves: 2}, want: []Note{61, 73, 85}},
+		{name: "unplayable notes left out", chord: NewChord(124, ChordMajor), want: []Note{124}},
+		{name: "no root", chord: NewChord(NoteNone, ChordMajor), want: []Note{}},
+		{name: "no intervals", chord: Chord{Root: NoteC5}, voicing: Voicing{Inversion: 1}, want: []Note{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.chord.Notes(test.voicing); !slices.Equal(got, test.want) {
+				t.Errorf("got notes %v, want %v", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestChordsFromScales(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		chord Chord
+		want  []Interval
+	}{
+		{name: "triad from degrees", chord: NewChordFromDegrees(NoteC5, ScaleMajor, 1, 3, 5), want: []Interval{0, 4, 7}},
+		{name: "degree in the next octave", chord: NewChordFromDegrees(NoteC5, ScaleMajor, 1, 9), want: []Interval{0, 14}},
+		{name: "degree in the octave below", chord: NewChordFromDegrees(NoteC5, ScaleMajor, 0, 1), want: []Interval{-1, 0}},
+		{name: "empty scale", chord: NewChordFromDegrees(NoteC5, Scale{}, 1, 3, 5), want: nil},
+		{name: "minor chord on degree 2", chord: ScaleMajor.Chord(NoteC5, 2, 3), want: []Interval{2, 5, 9}},
+		{name: "seventh chord on degree 7", chord: ScaleMajor.Chord(NoteC5, 7, 4), want: []Interval{11, 14, 17, 21}},
+		{name: "minor scale triad", chord: ScaleMinor.Chord(NoteA4, 1, 3), want: []Interval{0, 3, 7}},
+		{name: "no notes", chord: ScaleMajor.Chord(NoteC5, 1, 0), want: nil},
+		{name: "negative notes", chord: ScaleMajor.Chord(NoteC5, 1, -2), want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !slices.Equal(test.chord.Intervals, test.want) {
+				t.Errorf("got intervals %v, want %v", test.chord.Intervals, test.want)
+			}
+		})
+	}
+
+}
+
+func TestScaleDegree(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		scale  Scale
+		root   Note
+		degree int
+		want   Note
+		wantOk bool
+	}{
+		{name: "root", scale: ScaleMajor, root: NoteC5, degree: 1, want: 61, wantOk: true},
+		{name: "fifth", scale: ScaleMajor, root: NoteC5, degree: 5, want: 68, wantOk: true},
+		{name: "octave", scale: ScaleMajor, root: NoteC5, degree: 8, want: 73, wantOk: true},
+		{name: "below the root", scale: ScaleMajor, root: NoteC5, degree: 0, want: 60, wantOk: true},
+		{name: "an octave below", scale: ScaleMajor, root: NoteC5, degree: -6, want: 49, wantOk: true},
+		{name: "pentatonic", scale: ScaleMinorPentatonic, root: NoteA4, degree: 4, want: 65, wantOk: true},
+		{name: "unplayable", scale: ScaleMajor, root: NoteMax, degree: 2, want: NoteMax, wantOk: false},
+		{name: "empty scale", scale: Scale{}, root: NoteC5, degree: 1, want: NoteNone, wantOk: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.scale.Degree(test.root, test.degree)
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+
+}
+
+func TestScaleNotes(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		scale   Scale
+		root    Note
+		octaves int
+		want    []Note
+	}{
+		{name: "one octave", scale: ScaleMajorPentatonic, root: NoteC5, octaves: 1, want: []Note{61, 63, 65, 68, 70, 73}},
+		{name: "0 octaves", scale: ScaleMajorPentatonic, root: NoteC5, octaves: 0, want: []Note{61, 63, 65, 68, 70, 73}},
+		{name: "two octaves", scale: ScaleWholeTone, root: NoteC5, octaves: 2, want: []Note{61, 63, 65, 67, 69, 71, 73, 75, 77, 79, 81, 83, 85}},
+		{name: "unplayable notes left out", scale: ScaleMajor, root: 120, octaves: 1, want: []Note{120, 122, 124, 125, 127}},
+		{name: "no root", scale: ScaleMajor, root: NoteNone, octaves: 1, want: []Note{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.scale.Notes(test.root, test.octaves); !slices.Equal(got, test.want) {
+				t.Errorf("got notes %v, want %v", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestScaleQuantize(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		scale        Scale
+		root         Note
+		note         Note
+		want         Note
+		wantContains bool
+	}{
+		{name: "in the scale", scale: ScaleMajor, root: NoteC5, note: 65, want: 65, wantContains: true},
+		{name: "in another octave", scale: ScaleMajor, root: NoteC5, note: 77, want: 77, wantContains: true},
+		{name: "lower one on a tie", scale: ScaleMajor, root: NoteC5, note: 62, want: 61},
+		{name: "F# to F", scale: ScaleMajor, root: NoteC5, note: 67, want: 66},
+		{name: "closer one above", scale: ScaleMinorPentatonic, root: NoteC5, note: 63, want: 64},
+		{name: "below the root", scale: ScaleMajor, root: NoteC5, note: 59, want: 58},
+		{name: "note command", scale: ScaleMajor, root: NoteC5, note: NoteCommandNoteOff, want: NoteCommandNoteOff},
+		{name: "no note", scale: ScaleMajor, root: NoteC5, note: NoteNone, want: NoteNone},
+		{name: "empty scale", scale: Scale{}, root: NoteC5, note: 62, want: 62},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.scale.Quantize(test.root, test.note); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if got := test.scale.Contains(test.root, test.note); got != test.wantContains {
+				t.Errorf("got Contains() %v, want %v", got, test.wantContains)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,308 @@
+package sunvoxgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	timeMapSpeed      = 0 // Each line of the time map holds the line's BPM | TPL << 16
+	timeMapFrameCount = 1 // Each line of the time map holds the frame the line starts on
+)
+
+// MusicalPosition represents a position in a song in musical terms, according to the SunvoxChannel's time signature
+// (see SunvoxChannel.SetTimeSignature()). Bars and beats are counted from 0 from the beginning of the song.
+type MusicalPosition struct {
+	Bar  int // The bar the position is in
+	Beat int // The beat within the bar, in the time signature's beat unit (so it ranges from 0 to 5 in 6/8 time)
+	Line int // The line the position is on
+	Tick int // The tick within the line
+
+	Beats    float64 // The number of beats (in the time signature's beat unit) from the beginning of the song to the position
+	Position float64 // The position in lines, including how far into the line it is
+}
+
+// String returns the MusicalPosition in a readable format, with bars and beats counting from 1 (as in most music software).
+func (m MusicalPosition) String() string {
+	return fmt.Sprintf("bar %d, beat %d, line %d, tick %d", m.Bar+1, m.Beat+1, m.Line, m.Tick)
+}
+
+// MusicalPosition returns the current position of the playhead in musical terms. While playing, the position is
+// interpolated between updates from Sunvox's audio engine (see InterpolatedPosition()).
+func (s *SunvoxChannel) MusicalPosition() MusicalPosition {
+	return s.MusicalPositionAt(s.InterpolatedPosition())
+}
+
+// MusicalPositionAt returns the given position (in lines, like the values returned from CurrentPosition()) in musical
+// terms. Changes in TPL throughout the song are taken into account through the song's time map (see RefreshTimeMap()).
+func (s *SunvoxChannel) MusicalPositionAt(position float64) MusicalPosition {
+	position = max(position, 0)
+	lineTicks, tpl := s.ticksAt(int(math.Floor(position)))
+	return musicalPosition(position, lineTicks, tpl, s.TimeSignature())
+}
+
+// musicalPosition returns the given position (in lines) in musical terms, given the number of ticks from the beginning
+// of the song to the start of the position's line, and the line's TPL.
+func musicalPosition(position, lineTicks float64, tpl int, signature TimeSignature) MusicalPosition {
+
+	line := int(math.Floor(position))
+	fraction := position - float64(line)
+
+	ticks := lineTicks + fraction*float64(tpl)
+
+	beats := ticks / beatTicks(1, signature)
+
+	// The small offset keeps positions that are rounded down onto a beat from landing at the end of the previous one
+	wholeBeats := int(math.Floor(beats + 1e-9))
+
+	return MusicalPosition{
+		Bar:      wholeBeats / signature.Beats,
+		Beat:     wholeBeats % signature.Beats,
+		Line:     line,
+		Tick:     int(fraction * float64(tpl)),
+		Beats:    beats,
+		Position: position,
+	}
+
+}
+
+// InterpolatedPosition returns the current position of the playhead in lines, like CurrentPosition(). While playing,
+// the time elapsed since the position last changed is used to estimate the position in between Sunvox's updates (which
+// only happen once per audio buffer), so it advances smoothly.
+func (s *SunvoxChannel) InterpolatedPosition() float64 {
+
+	position := s.CurrentPosition()
+	now := time.Now()
+
+	s.clockMutex.Lock()
+	defer s.clockMutex.Unlock()
+
+	if position != s.clockPosition || s.State() != StatePlaying {
+		s.clockPosition = position
+		s.clockTime = now
+		return position
+	}
+
+	// The estimate is limited so that it can't run far ahead if Sunvox stops updating for some reason
+	elapsed := min(now.Sub(s.clockTime), max(engine.Latency()*2, time.Millisecond*50))
+
+	return position + elapsed.Seconds()*float64(s.LPM())/60
+
+}
+
+// RefreshTimeMap rebuilds the SunvoxChannel's cached time map, which is used to account for changes in TPL throughout
+// the song when determining musical positions. It's built automatically the first time it's needed after a project is
+// loaded, but should be refreshed if the project's tempo effects are changed.
+func (s *SunvoxChannel) RefreshTimeMap() {
+	s.clockMutex.Lock()
+	defer s.clockMutex.Unlock()
+	s.buildTimeMap()
+}
+
+// OnBeat adds a callback to be called when the playhead reaches a new beat.
+// This is a shortcut for Subscribe() with EventBeat; see Subscribe() for more information.
+func (s *SunvoxChannel) OnBeat(ctx context.Context, onBeat func(position MusicalPosition)) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onBeat(event.MusicalPosition)
+		return true
+	}, EventBeat)
+}
+
+// OnBar adds a callback to be called when the playhead reaches a new bar.
+// This is a shortcut for Subscribe() with EventBar; see Subscribe() for more information.
+func (s *SunvoxChannel) OnBar(ctx context.Context, onBar func(position MusicalPosition)) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onBar(event.MusicalPosition)
+		return true
+	}, EventBar)
+}
+
+// beatTicks returns the number of ticks from the beginning of the song to the start of the given beat (in the time
+// signature's beat unit).
+func beatTicks(beat int, signature TimeSignature) float64 {
+	// In Sunvox, 1 beat (quarter note) = 24 ticks
+	return float64(beat) * 24 * 4 / float64(signature.Unit)
+}
+
+// positionOfBeat returns the position (in lines) at which the given beat (counted from the beginning of the song) starts.
+func (s *SunvoxChannel) positionOfBeat(beat int) float64 {
+
+	ticks := beatTicks(beat, s.TimeSignature())
+
+	s.clockMutex.Lock()
+	defer s.clockMutex.Unlock()
+
+	if s.lineTicks == nil {
+		s.buildTimeMap()
+	}
+
+	tpl := 0
+	if ticks >= float64(s.lineTicks[len(s.lineTPL)]) {
+		tpl = max(s.TPL(), 1)
+	}
+
+	return positionAtTicks(ticks, s.lineTicks, s.lineTPL, tpl)
+
+}
+
+// positionAtTicks returns the position (in lines) that is the given number of ticks from the beginning of the song,
+// given the number of ticks to the start of each line (with an extra one for the end of the song) and the TPL of each
+// line. Past the end of the song, lines are tpl ticks long.
+func positionAtTicks(ticks float64, lineTicks []int, lineTPL []int, tpl int) float64 {
+
+	end := len(lineTPL)
+
+	if ticks >= float64(lineTicks[end]) {
+		return float64(end) + (ticks-float64(lineTicks[end]))/float64(max(tpl, 1))
+	}
+
+	// The last line that starts at or before the given tick
+	line, found := slices.BinarySearch(lineTicks[:end], int(math.Floor(ticks)))
+	if !found {
+		line--
+	}
+	line = max(line, 0)
+
+	return float64(line) + (ticks-float64(lineTicks[line]))/float64(lineTPL[line])
+
+}
+
+// ticksAt returns the number of ticks from the beginning of the song to the start of the given line, along with the TPL
+// of the line.
+func (s *SunvoxChannel) ticksAt(line int) (float64, int) {
+
+	s.clockMutex.Lock()
+	defer s.clockMutex.Unlock()
+
+	if s.lineTicks == nil {
+		s.buildTimeMap()
+	}
+
+	// Past the end of the time map, the song's current TPL is assumed
+	if line >= len(s.lineTPL) {
+		tpl := max(s.TPL(), 1)
+		extra := line - len(s.lineTPL)
+		return float64(s.lineTicks[len(s.lineTPL)] + extra*tpl), tpl
+	}
+
+	return float64(s.lineTicks[line]), s.lineTPL[line]
+
+}
+
+// buildTimeMap builds the cached TPL and tick count of each line in the song. clockMutex must be held.
+func (s *SunvoxChannel) buildTimeMap() {
+
+	lineCount := s.LengthInLines()
+
+	s.lineTPL = make([]int, lineCount)
+	s.lineTicks = make([]int, lineCount+1)
+
+	if lineCount > 0 {
+
+		speeds := make([]uint32, lineCount)
+
+		if res := getTimeMap(s.Index, 0, lineCount, &speeds[0], timeMapSpeed); res != 0 {
+			// If the time map is unavailable, the song's current TPL is used throughout
+			tpl := max(s.TPL(), 1)
+			for i := range speeds {
+				speeds[i] = uint32(tpl) << 16
+			}
+		}
+
+		for i, speed := range speeds {
+			s.lineTPL[i] = max(int(speed>>16), 1)
+			s.lineTicks[i+1] = s.lineTicks[i] + s.lineTPL[i]
+		}
+
+	}
+
+}
+
+// invalidateTimeMap clears the cached time map so that it's rebuilt the next time it's needed.
+func (s *SunvoxChannel) invalidateTimeMap() {
+	s.clockMutex.Lock()
+	defer s.clockMutex.Unlock()
+	s.lineTPL = nil
+	s.lineTicks = nil
+}
+
+// lineClock tracks a position in lines that follows a SunvoxChannel's playhead while it's playing, and runs by itself at
+// a set tempo while it isn't, so that things played from Go (like a StepSequencer's steps) can stay in time with the song.
+type lineClock struct {
+	bpm float64 // The tempo the clock runs at by itself; if 0, the channel's BPM is used
+	tpl int     // The TPL the clock runs at by itself; if 0, the channel's TPL is used
+
+	synced bool    // Whether the clock is following the channel's playhead
+	line   float64 // The clock's position as of the last update
+
+	freeLine float64 // The position of the clock when it began running by itself
+	freeTime time.Time
+}
+
+// reset restarts the clock from the beginning of the song (or from the channel's playhead, if it's playing).
+func (c *lineClock) reset(now time.Time) {
+	c.synced = false
+	c.line = 0
+	c.freeLine = 0
+	c.freeTime = now
+}
+
+// update advances the clock, returning its position and speed in lines per minute. If the speed can't be determined,
+// ok is false.
+func (c *lineClock) update(channel *SunvoxChannel, now time.Time) (line, lpm float64, ok bool) {
+
+	if channel.State() == StatePlaying {
+
+		lpm = float64(channel.LPM())
+
+		if lpm > 0 && !math.IsInf(lpm, 0) {
+			c.follow(max(channel.InterpolatedPosition(), 0))
+			return c.line, lpm, true
+		}
+
+	}
+
+	bpm := c.bpm
+	if bpm <= 0 {
+		bpm = float64(channel.BPM())
+	}
+
+	tpl := c.tpl
+	if tpl <= 0 {
+		tpl = channel.TPL()
+	}
+
+	if bpm <= 0 || tpl <= 0 {
+		return c.line, 0, false
+	}
+
+	lpm = bpm * 24 / float64(tpl)
+
+	return c.run(lpm, now), lpm, true
+
+}
+
+// follow sets the clock's position to the channel's playhead.
+func (c *lineClock) follow(line float64) {
+	c.synced = true
+	c.line = line
+}
+
+// run advances the clock by itself at the given speed (in lines per minute), returning its position. When the clock
+// stops following the channel's playhead, it carries on from where the playhead was.
+func (c *lineClock) run(lpm float64, now time.Time) float64 {
+
+	if c.synced {
+		c.synced = false
+		c.freeLine = c.line
+		c.freeTime = now
+	}
+
+	c.line = c.freeLine + now.Sub(c.freeTime).Minutes()*lpm
+
+	return c.line
+
+}
@@ -0,0 +1,180 @@
+package sunvoxgo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMusicalPosition(t *testing.T) {
+
+	fourFour := TimeSignature{Beats: 4, Unit: 4}
+	sixEight := TimeSignature{Beats: 6, Unit: 8}
+
+	tests := []struct {
+		name      string
+		position  float64
+		lineTicks float64
+		tpl       int
+		signature TimeSignature
+		want      MusicalPosition
+	}{
+		{
+			name: "beginning", position: 0, lineTicks: 0, tpl: 6, signature: fourFour,
+			want: MusicalPosition{Bar: 0, Beat: 0, Line: 0, Tick: 0, Beats: 0, Position: 0},
+		},
+		{
+			name: "second beat", position: 4, lineTicks: 24, tpl: 6, signature: fourFour,
+			want: MusicalPosition{Bar: 0, Beat: 1, Line: 4, Tick: 0, Beats: 1, Position: 4},
+		},
+		{
+			name: "partway into a line", position: 17.5, lineTicks: 102, tpl: 6, signature: fourFour,
+			want: MusicalPosition{Bar: 1, Beat: 0, Line: 17, Tick: 3, Beats: 4.375, Position: 17.5},
+		},
+		{
+			name: "different tpl", position: 8, lineTicks: 24, tpl: 3, signature: fourFour,
+			want: MusicalPosition{Bar: 0, Beat: 1, Line: 8, Tick: 0, Beats: 1, Position: 8},
+		},
+		{
+			name: "eighth note beats", position: 14, lineTicks: 84, tpl: 6, signature: sixEight,
+			want: MusicalPosition{Bar: 1, Beat: 1, Line: 14, Tick: 0, Beats: 7, Position: 14},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := musicalPosition(test.position, test.lineTicks, test.tpl, test.signature)
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestPositionAtTicks(t *testing.T) {
+
+	// Four lines at TPL 6, then four lines at TPL 3
+	lineTPL := []int{6, 6, 6, 6, 3, 3, 3, 3}
+	lineTicks := []int{0, 6, 12, 18, 24, 27, 30, 33, 36}
+
+	tests := []struct {
+		name  string
+		ticks float64
+		tpl   int
+		want  float64
+	}{
+		{name: "beginning", ticks: 0, want: 0},
+		{name: "start of a line", ticks: 12, want: 2},
+		{name: "partway into a line", ticks: 15, want: 2.5},
+		{name: "after a tpl change", ticks: 28, want: 5 + 1.0/3},
+		{name: "end of the song", ticks: 36, tpl: 6, want: 8},
+		{name: "past the end", ticks: 48, tpl: 6, want: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := positionAtTicks(test.ticks, lineTicks, lineTPL, test.tpl); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got position %v, want %v", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestBeatStartRoundTrip(t *testing.T) {
+
+	lineTPL := []int{6, 6, 6, 6, 3, 3, 3, 3, 3, 3, 3, 3}
+	lineTicks := []int{0}
+	for _, tpl := range lineTPL {
+		lineTicks = append(lineTicks, lineTicks[len(lineTicks)-1]+tpl)
+	}
+
+	for _, signature := range []TimeSignature{{Beats: 4, Unit: 4}, {Beats: 6, Unit: 8}, {Beats: 7, Unit: 16}} {
+		for beat := 0; beatTicks(beat, signature) < float64(lineTicks[len(lineTPL)]); beat++ {
+
+			position := positionAtTicks(beatTicks(beat, signature), lineTicks, lineTPL, 3)
+			line := int(math.Floor(position))
+
+			got := musicalPosition(position, float64(lineTicks[line]), lineTPL[line], signature)
+
+			if got.Bar*signature.Beats+got.Beat != beat {
+				t.Errorf("%d/%d: beat %d starts at position %v, which is in bar %d, beat %d", signature.Beats, signature.Unit, beat, position, got.Bar, got.Beat)
+			}
+
+		}
+	}
+
+}
+
+func TestLineClock(t *testing.T) {
+
+	start := time.Now()
+	at := func(seconds float64) time.Time { return start.Add(time.Duration(seconds * float64(time.Second))) }
+
+	type step struct {
+		follow  float64 // If 0 or above, the clock follows the playhead to this position; otherwise, it runs by itself
+		seconds float64
+		lpm     float64
+		want    float64
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "runs by itself",
+			steps: []step{
+				{follow: -1, seconds: 0, lpm: 120, want: 0},
+				{follow: -1, seconds: 1, lpm: 120, want: 2},
+				{follow: -1, seconds: 2.5, lpm: 120, want: 5},
+			},
+		},
+		{
+			name: "carries on from the playhead",
+			steps: []step{
+				{follow: 10, seconds: 0},
+				{follow: 12, seconds: 1},
+				{follow: -1, seconds: 1, lpm: 60, want: 12},
+				{follow: -1, seconds: 3, lpm: 60, want: 14},
+			},
+		},
+		{
+			name: "follows the playhead again",
+			steps: []step{
+				{follow: -1, seconds: 0, lpm: 60, want: 0},
+				{follow: -1, seconds: 2, lpm: 60, want: 2},
+				{follow: 32, seconds: 2.5, want: 32},
+				{follow: -1, seconds: 3.5, lpm: 60, want: 32},
+				{follow: -1, seconds: 4.5, lpm: 60, want: 33},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := &lineClock{}
+			c.reset(start)
+
+			for i, s := range test.steps {
+
+				if s.follow >= 0 {
+					c.follow(s.follow)
+					if c.line != s.follow {
+						t.Errorf("step %d: got position %v following the playhead, want %v", i, c.line, s.follow)
+					}
+					continue
+				}
+
+				if got := c.run(s.lpm, at(s.seconds)); math.Abs(got-s.want) > 1e-9 {
+					t.Errorf("step %d: got position %v, want %v", i, got, s.want)
+				}
+
+			}
+
+		})
+	}
+
+}
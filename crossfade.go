@@ -0,0 +1,130 @@
+package sunvoxgo
+
+import (
+	"sync"
+	"time"
+)
+
+// Crossfade represents a crossfade between two SunvoxChannels started through SunvoxEngine.Crossfade().
+type Crossfade struct {
+	FadeOut *VolumeFade // The fade for the channel being faded out; nil if there isn't one
+	FadeIn  *VolumeFade // The fade for the channel being faded in; nil if there isn't one
+
+	fadeOuts  []*VolumeFade // The fades for all of the channels being faded out, including FadeOut
+	done      chan struct{}
+	doneOnce  sync.Once
+	remaining int
+	mutex     sync.Mutex
+}
+
+// Crossfade fades out the from channel while fading in the to channel over the given duration, using the given Curve
+// for the incoming channel's volume (the outgoing channel's volume mirrors it). If curve is nil, CurveEqualPower is used.
+// Either channel can be nil, to just fade one channel in or out.
+//
+// The to channel is faded in from silence up to full volume; if it isn't playing already, it's played from the beginning.
+// The from channel is faded out from its current volume and stopped once it's silent.
+//
+// The crossfade runs by itself, updated by the engine (see SunvoxEngine.SetUpdateMode()).
+func (e *SunvoxEngine) Crossfade(from, to *SunvoxChannel, duration time.Duration, curve Curve) *Crossfade {
+	if from == nil {
+		return e.crossfade(nil, to, duration, curve)
+	}
+	return e.crossfade([]*SunvoxChannel{from}, to, duration, curve)
+}
+
+// crossfade fades out all of the from channels together while fading in the to channel, as with Crossfade().
+// FadeOut is set to the fade for the first of the from channels.
+func (e *SunvoxEngine) crossfade(from []*SunvoxChannel, to *SunvoxChannel, duration time.Duration, curve Curve) *Crossfade {
+
+	if curve == nil {
+		curve = CurveEqualPower
+	}
+
+	seconds := float32(duration.Seconds())
+
+	c := &Crossfade{
+		done: make(chan struct{}),
+	}
+
+	for _, channel := range from {
+		fade := NewVolumeFade(-1, 0, seconds, channel)
+		// The outgoing volume mirrors the incoming one, so an equal-power crossfade stays equal-power
+		fade.Curve = func(t float32) float32 { return 1 - curve(1-t) }
+		fade.OnFinish = c.finishFade
+		c.fadeOuts = append(c.fadeOuts, fade)
+		c.remaining++
+	}
+
+	if len(c.fadeOuts) > 0 {
+		c.FadeOut = c.fadeOuts[0]
+	}
+
+	if to != nil {
+		// Even if it's already playing, the channel is faded in from silence
+		to.SetVolume(0)
+		if !to.IsPlaying() {
+			to.PlayFromBeginning()
+		}
+		c.FadeIn = NewVolumeFade(0, 1, seconds, to)
+		c.FadeIn.Curve = curve
+		c.FadeIn.OnFinish = c.finishFade
+		c.remaining++
+	}
+
+	if c.remaining == 0 {
+		c.finish()
+		return c
+	}
+
+	for _, fade := range c.fadeOuts {
+		fade.Start()
+	}
+
+	if c.FadeIn != nil {
+		c.FadeIn.Start()
+	}
+
+	return c
+
+}
+
+// Stop stops the Crossfade, leaving both channels at whatever volume they're at.
+func (c *Crossfade) Stop() {
+	for _, fade := range c.fadeOuts {
+		fade.Stop()
+	}
+	if c.FadeIn != nil {
+		c.FadeIn.Stop()
+	}
+	c.finish()
+}
+
+// IsRunning returns if the Crossfade is still in progress.
+func (c *Crossfade) IsRunning() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Done returns a channel that is closed once the Crossfade finishes or is stopped.
+func (c *Crossfade) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Crossfade) finishFade() {
+	c.mutex.Lock()
+	c.remaining--
+	finished := c.remaining <= 0
+	c.mutex.Unlock()
+
+	if finished {
+		c.finish()
+	}
+}
+
+func (c *Crossfade) finish() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
@@ -0,0 +1,646 @@
+package sunvoxgo
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventType indicates what kind of playback event an Event represents.
+type EventType int
+
+const (
+	EventLineChanged    EventType = iota // The playhead moved to a different line
+	EventPatternEntered                  // The playhead started playing a pattern (patterns entered at once are ordered by X, then Y, then index)
+	EventPatternExited                   // The playhead finished playing a pattern (ordered like EventPatternEntered)
+	EventLooped                          // The playhead wrapped around back to an earlier line without being seeked, or reached the end of the active loop region
+	EventSongEnded                       // A song that doesn't loop reached its end
+	EventStopped                         // Playback was stopped through Stop()
+	EventMarker                          // The playhead reached a marker (see SunvoxChannel.Markers())
+	EventBeat                            // The playhead reached a new beat (see SunvoxChannel.MusicalPosition())
+	EventBar                             // The playhead reached a new bar (see SunvoxChannel.MusicalPosition())
+	EventNote                            // A note in a pattern was played (see SunvoxChannel.OnNote())
+)
+
+// String returns the name of the EventType.
+func (e EventType) String() string {
+	switch e {
+	case EventLineChanged:
+		return "LineChanged"
+	case EventPatternEntered:
+		return "PatternEntered"
+	case EventPatternExited:
+		return "PatternExited"
+	case EventLooped:
+		return "Looped"
+	case EventSongEnded:
+		return "SongEnded"
+	case EventStopped:
+		return "Stopped"
+	case EventMarker:
+		return "Marker"
+	case EventBeat:
+		return "Beat"
+	case EventBar:
+		return "Bar"
+	case EventNote:
+		return "Note"
+	}
+	return "Unknown"
+}
+
+// Event represents something that happened during playback on a SunvoxChannel.
+type Event struct {
+	Type    EventType
+	Channel *SunvoxChannel
+
+	Line         int            // The line the playhead was on when the event was detected
+	PreviousLine int            // The line the playhead was on when it was previously polled
+	Pattern      *SunvoxPattern // The pattern entered or exited (or the marker's pattern, for EventMarker events); nil otherwise
+	Marker       *Marker        // The marker reached, for EventMarker events; nil otherwise
+	Note         *NoteEvent     // The note played, for EventNote events; nil otherwise
+
+	MusicalPosition MusicalPosition // The start of the beat or bar reached (or the playhead's position, if it landed partway into it), for EventBeat and EventBar events
+
+	Time time.Time // When the event was detected
+}
+
+type eventListener struct {
+	sub     *Subscription
+	types   uint64
+	onEvent func(sub *Subscription, event Event) bool
+}
+
+func (l *eventListener) wants(eventType EventType) bool {
+	return l.types&(1<<eventType) > 0
+}
+
+// eventPoller tracks the playback state of a SunvoxChannel between polls so it can emit Events.
+type eventPoller struct {
+	listenerMutex sync.Mutex
+	listeners     []*eventListener
+
+	// dispatchMutex is held while callbacks are being called, so subscriptions can wait for any callback in progress
+	// to finish when they're canceled.
+	dispatchMutex sync.Mutex
+
+	started     bool
+	line        int
+	beat        int
+	noteLine    int
+	bar         int
+	state       PlaybackState
+	seekCount   uint64
+	playCount   uint64
+	touching    map[int]patternPosition
+	wasTouching map[int]patternPosition
+	events      []Event
+}
+
+// patternPosition holds where a pattern the playhead is in is placed in the project.
+type patternPosition struct {
+	index int
+	x, y  int
+}
+
+// comparePatternPositions orders patterns by their X position, then their Y position, and then their index.
+func comparePatternPositions(a, b patternPosition) int {
+	if c := cmp.Compare(a.x, b.x); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.y, b.y); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.index, b.index)
+}
+
+// OnEnd adds a callback to be called when a song that doesn't loop reaches its end (see State()).
+// This is a shortcut for Subscribe() with EventSongEnded; see Subscribe() for more information.
+func (s *SunvoxChannel) OnEnd(ctx context.Context, onEnd func()) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onEnd()
+		return true
+	}, EventSongEnded)
+}
+
+// OnLoop adds a callback to be called when playback wraps around back to the beginning of the song (or of the active
+// loop region).
+// This is a shortcut for Subscribe() with EventLooped; see Subscribe() for more information.
+func (s *SunvoxChannel) OnLoop(ctx context.Context, onLoop func()) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onLoop()
+		return true
+	}, EventLooped)
+}
+
+// Subscribe adds a callback to be called whenever one of the given types of playback events happens on the SunvoxChannel.
+// If no event types are given, the callback is called for all events.
+// (Note that Sunvox's audio engine is going to be ahead of the callback by some time.)
+//
+// ctx can be used to cancel the callback; if it's nil, the callback runs until it's canceled through the returned Subscription
+// or the channel closes. If onEvent returns false, the callback is canceled.
+//
+// All events for all channels are detected by a single goroutine that polls the engine (see SunvoxEngine.SetPollResolution()),
+// and callbacks are called on that goroutine, so they should return quickly.
+func (s *SunvoxChannel) Subscribe(ctx context.Context, onEvent func(event Event) bool, eventTypes ...EventType) *Subscription {
+	return s.subscribe(ctx, func(sub *Subscription, event Event) bool { return onEvent(event) }, eventTypes...)
+}
+
+func (s *SunvoxChannel) subscribe(ctx context.Context, onEvent func(sub *Subscription, event Event) bool, eventTypes ...EventType) *Subscription {
+
+	listener := &eventListener{
+		onEvent: onEvent,
+	}
+
+	if len(eventTypes) == 0 {
+		listener.types = ^uint64(0)
+	}
+
+	for _, t := range eventTypes {
+		listener.types |= 1 << t
+	}
+
+	listener.sub = s.newSubscription(ctx, func() {
+
+		s.poller.listenerMutex.Lock()
+		for i, l := range s.poller.listeners {
+			if l == listener {
+				s.poller.listeners = append(s.poller.listeners[:i], s.poller.listeners[i+1:]...)
+				break
+			}
+		}
+		s.poller.listenerMutex.Unlock()
+
+		// Wait for any callback that's currently running to finish
+		s.poller.dispatchMutex.Lock()
+		s.poller.dispatchMutex.Unlock()
+
+	})
+
+	// If the Subscription has already been canceled (i.e. the channel is closed), there's no need to add it
+	s.poller.listenerMutex.Lock()
+	if listener.sub.ctx.Err() == nil {
+		s.poller.listeners = append(s.poller.listeners, listener)
+	}
+	s.poller.listenerMutex.Unlock()
+
+	engine.startPolling()
+
+	return listener.sub
+
+}
+
+// Events returns a Go channel that receives the given types of playback events that happen on the SunvoxChannel, along with
+// the Subscription for it. If no event types are given, all events are sent. The Go channel is closed once the Subscription
+// is canceled. See Subscribe() for more information.
+//
+// bufferSize is the size of the Go channel's buffer. Note that if the buffer is full, the polling goroutine waits for
+// room, delaying events for all channels, so events should be received promptly.
+func (s *SunvoxChannel) Events(ctx context.Context, bufferSize int, eventTypes ...EventType) (<-chan Event, *Subscription) {
+
+	events := make(chan Event, bufferSize)
+
+	sub := s.subscribe(ctx, func(sub *Subscription, event Event) bool {
+		select {
+		case events <- event:
+			return true
+		case <-sub.ctx.Done():
+			return false
+		}
+	}, eventTypes...)
+
+	go func() {
+		<-sub.Done()
+		close(events)
+	}()
+
+	return events, sub
+
+}
+
+// poll checks the playback state of the SunvoxChannel and sends Events to its listeners.
+func (s *SunvoxChannel) poll(now time.Time) {
+
+	p := &s.poller
+
+	p.listenerMutex.Lock()
+	listeners := append([]*eventListener{}, p.listeners...)
+	p.listenerMutex.Unlock()
+
+	// Channels are polled while they're playing (to count loops, for example) or while anything is listening to them
+	if len(listeners) == 0 && !s.playing.Load() {
+		p.started = false
+		return
+	}
+
+	wantsPatterns := false
+	wantsMarkers := false
+	wantsClock := false
+	wantsNotes := false
+	for _, l := range listeners {
+		if l.wants(EventPatternEntered) || l.wants(EventPatternExited) {
+			wantsPatterns = true
+		}
+		if l.wants(EventMarker) {
+			wantsMarkers = true
+		}
+		if l.wants(EventBeat) || l.wants(EventBar) {
+			wantsClock = true
+		}
+		if l.wants(EventNote) {
+			wantsNotes = true
+		}
+	}
+
+	line := s.CurrentLine()
+	state := s.State()
+	seekCount := s.seekCount.Load()
+	playCount := s.playCount.Load()
+
+	p.events = p.events[:0]
+
+	restarted := !p.started || playCount != p.playCount
+
+	region := regionNone
+	if state == StatePlaying {
+		line, region = s.updateLoopRegion(p.line, line, restarted || seekCount != p.seekCount)
+		if region == regionEnded {
+			state = StateEnded
+		}
+	}
+
+	event := func(eventType EventType, pattern *SunvoxPattern) {
+		p.events = append(p.events, Event{
+			Type:         eventType,
+			Channel:      s,
+			Line:         line,
+			PreviousLine: p.line,
+			Pattern:      pattern,
+			Time:         now,
+		})
+	}
+
+	looped := false
+
+	if !p.started {
+		p.started = true
+		p.line = line
+		p.state = state
+		p.seekCount = seekCount
+		p.playCount = playCount
+		p.touching = map[int]patternPosition{}
+		p.wasTouching = map[int]patternPosition{}
+		event(EventLineChanged, nil)
+	} else {
+
+		// If the playhead goes backwards while playing without having been seeked (or moved into a loop region), it must
+		// have wrapped around
+		looped = region == regionLooped || (region == regionNone && line < p.line && state == StatePlaying && p.state == StatePlaying && seekCount == p.seekCount && playCount == p.playCount)
+
+		if looped {
+			event(EventLooped, nil)
+		}
+
+		if line != p.line {
+			event(EventLineChanged, nil)
+		}
+
+		if wantsMarkers {
+
+			// Markers are reached by playing up to them, or by landing on them directly
+			from := p.line
+			if restarted || seekCount != p.seekCount || region == regionEntered {
+				from = line - 1
+			} else if looped {
+				from = -1
+				if r, ok := s.ActiveLoopRegion(); ok && region == regionLooped {
+					from = r.Start - 1
+				}
+			}
+
+			s.markersBetween(from, line, func(marker Marker) {
+				event(EventMarker, marker.Pattern)
+				p.events[len(p.events)-1].Marker = &marker
+			})
+
+		}
+
+	}
+
+	if wantsClock {
+
+		// The fractional position is only used if it agrees with the line (which may have been moved by a loop region)
+		position := s.CurrentPosition()
+		if int(math.Floor(position)) != line {
+			position = float64(line)
+		}
+
+		musical := s.MusicalPositionAt(position)
+		beat := int(math.Floor(musical.Beats + 1e-9))
+
+		// Landing exactly on a beat counts as reaching it; landing partway through one doesn't
+		if restarted || seekCount != p.seekCount || region == regionEntered {
+			p.beat = beat
+			p.bar = musical.Bar
+			if musical.Beats-float64(beat) < 1e-6 {
+				p.beat--
+				if musical.Beat == 0 {
+					p.bar--
+				}
+			}
+		}
+
+		if state == StatePlaying && beat != p.beat {
+
+			// Each beat crossed since the last poll gets an event of its own (positioned at the beat's start), so none
+			// are skipped if a poll is late; after looping, only the beat that the playhead landed in is reached
+			first := p.beat + 1
+			landed := looped || beat < first
+			if landed {
+				first = beat
+			}
+
+			bar := p.bar
+
+			for b := first; b <= beat; b++ {
+
+				position := musical
+				if !landed {
+					position = s.MusicalPositionAt(s.positionOfBeat(b))
+				}
+
+				event(EventBeat, nil)
+				p.events[len(p.events)-1].MusicalPosition = position
+
+				if position.Bar != bar || (looped && b == first && position.Beat == 0) {
+					event(EventBar, nil)
+					p.events[len(p.events)-1].MusicalPosition = position
+				}
+
+				bar = position.Bar
+
+			}
+
+		}
+
+		p.beat = beat
+		p.bar = musical.Bar
+
+	}
+
+	if wantsNotes {
+
+		// Like markers, notes are played by playing up to them, or by landing on them directly; the lines already
+		// scanned ahead of the playhead are skipped
+		from := p.noteLine
+		if restarted || seekCount != p.seekCount || region == regionEntered {
+			from = line - 1
+		} else if looped {
+			from = -1
+			if r, ok := s.ActiveLoopRegion(); ok && region == regionLooped {
+				from = r.Start - 1
+			}
+		}
+
+		if state == StatePlaying {
+			to := s.noteScanTarget(line)
+			s.notesBetween(from, to, now, func(note NoteEvent) {
+				event(EventNote, note.Pattern)
+				p.events[len(p.events)-1].Note = &note
+			})
+			from = max(from, to)
+		}
+
+		p.noteLine = from
+
+	}
+
+	if wantsPatterns {
+
+		s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+			lc, _ := pattern.LineCount()
+			x := pattern.X()
+
+			if line >= x && line < x+lc {
+				p.touching[pattern.Index] = patternPosition{index: pattern.Index, x: x, y: pattern.Y()}
+			}
+			return true
+		})
+
+		// The patterns are gathered from maps, so they're sorted to emit their events in a consistent order (exited
+		// patterns by where they were last seen, as they may have been moved or removed since)
+		changed := []patternPosition{}
+
+		for patternIndex, position := range p.wasTouching {
+			if _, nowTouching := p.touching[patternIndex]; !nowTouching {
+				changed = append(changed, position)
+			}
+		}
+
+		slices.SortFunc(changed, comparePatternPositions)
+
+		for _, position := range changed {
+			event(EventPatternExited, s.PatternByIndex(position.index))
+		}
+
+		changed = changed[:0]
+
+		for patternIndex, position := range p.touching {
+			if _, wasTouching := p.wasTouching[patternIndex]; !wasTouching {
+				changed = append(changed, position)
+			}
+		}
+
+		slices.SortFunc(changed, comparePatternPositions)
+
+		for _, position := range changed {
+			event(EventPatternEntered, s.PatternByIndex(position.index))
+		}
+
+		p.wasTouching, p.touching = p.touching, p.wasTouching
+		clear(p.touching)
+
+	}
+
+	// If playback was started again since the last poll, the song may have ended again
+	if state == StateEnded && (p.state != StateEnded || playCount != p.playCount) {
+		event(EventSongEnded, nil)
+	}
+
+	if state == StateStopped && p.state != StateStopped {
+		event(EventStopped, nil)
+	}
+
+	p.line = line
+	p.state = state
+	p.seekCount = seekCount
+	p.playCount = playCount
+
+	afterLoops := s.updateLoops(p.events, restarted)
+
+	if len(p.events) > 0 {
+		engine.beginCallbacks()
+		p.dispatch(listeners)
+		engine.endCallbacks()
+	}
+
+	// A callback could have closed the channel
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if afterLoops != nil {
+		afterLoops()
+	}
+
+	s.updateTransitions()
+
+}
+
+// dispatch calls the listeners' callbacks for the events detected in the last poll.
+func (p *eventPoller) dispatch(listeners []*eventListener) {
+
+	p.dispatchMutex.Lock()
+	defer p.dispatchMutex.Unlock()
+
+	for _, event := range p.events {
+		for _, l := range listeners {
+			if l.wants(event.Type) && l.sub.ctx.Err() == nil {
+				if !l.onEvent(l.sub, event) {
+					l.sub.Cancel()
+				}
+			}
+		}
+	}
+
+}
+
+// SetPollResolution sets how often the engine polls channels for playback events (see SunvoxChannel.Subscribe()).
+// If less than or equal to 0, it will be the default (10ms / 100 times per second).
+func (e *SunvoxEngine) SetPollResolution(pollResolution time.Duration) {
+	if pollResolution <= 0 {
+		pollResolution = time.Millisecond * 10
+	}
+	e.pollResolution.Store(int64(pollResolution))
+}
+
+// PollResolution returns how often the engine polls channels for playback events.
+func (e *SunvoxEngine) PollResolution() time.Duration {
+	return time.Duration(e.pollResolution.Load())
+}
+
+// startPolling starts the goroutine that polls channels for playback events, if it isn't running already
+// and the engine's update mode is UpdateModeGoroutine.
+func (e *SunvoxEngine) startPolling() {
+
+	e.pollMutex.Lock()
+	defer e.pollMutex.Unlock()
+
+	if e.pollCancel != nil || UpdateMode(e.updateMode.Load()) != UpdateModeGoroutine {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	e.pollCancel = cancel
+	e.pollFinished = finished
+
+	go func() {
+
+		defer close(finished)
+
+		e.resetTaskClock()
+
+		timer := time.NewTimer(e.PollResolution())
+		defer timer.Stop()
+
+		for {
+
+			e.poll(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				timer.Reset(e.PollResolution())
+			}
+
+		}
+
+	}()
+
+}
+
+// stopPolling stops the goroutine that polls channels for playback events and waits for it to exit.
+func (e *SunvoxEngine) stopPolling() {
+
+	e.pollMutex.Lock()
+	defer e.pollMutex.Unlock()
+
+	if e.pollCancel == nil {
+		return
+	}
+
+	// Polling is canceled while no callback can begin or end, so the poller sees it's stopped as soon as its callbacks
+	// are finished. If callbacks are being called (as when the engine is deinitialized from one), the poller can't exit
+	// until they return, so it isn't waited for; it exits once they do.
+	e.callbackMutex.Lock()
+	e.pollCancel()
+	wait := e.callbacks == 0
+	e.callbackMutex.Unlock()
+
+	if wait {
+		<-e.pollFinished
+	}
+
+	e.pollCancel = nil
+	e.pollFinished = nil
+
+}
+
+// poll polls all channels for playback events and updates the engine's tasks. It stops early if ctx is canceled by a
+// callback.
+func (e *SunvoxEngine) poll(ctx context.Context, now time.Time) {
+
+	for _, c := range e.channelList() {
+		if ctx.Err() != nil {
+			return
+		}
+		// A callback could have closed the channel (or deinitialized the engine) during this poll
+		if c.ctx.Err() == nil {
+			c.poll(now)
+		}
+	}
+
+	if e.Initialized && ctx.Err() == nil {
+		e.beginCallbacks()
+		e.updateTasks(now)
+		e.endCallbacks()
+	}
+
+}
+
+// beginCallbacks marks that the engine is calling callbacks, until endCallbacks is called.
+func (e *SunvoxEngine) beginCallbacks() {
+	e.callbackMutex.Lock()
+	e.callbacks++
+	e.callbackMutex.Unlock()
+}
+
+// endCallbacks marks that the engine has finished calling callbacks.
+func (e *SunvoxEngine) endCallbacks() {
+	e.callbackMutex.Lock()
+	e.callbacks--
+	e.callbackMutex.Unlock()
+}
+
+// inCallbacks returns if the engine is currently calling callbacks (or updating tasks, like Tweens); that is, if
+// anything that waits for callbacks to finish would wait forever when called from one.
+func (e *SunvoxEngine) inCallbacks() bool {
+	e.callbackMutex.Lock()
+	defer e.callbackMutex.Unlock()
+	return e.callbacks > 0
+}
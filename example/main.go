@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 
@@ -56,48 +57,12 @@ func main() {
 			channel.Stop()
 			fmt.Println("Playback stopped")
 		case "p+":
-			channel.ForEachPattern(func(p *sunvoxgo.SunvoxPattern) bool {
-				patternData, err := p.Data()
-				if err != nil {
-					panic(err) // Should never happen
-				}
-				// Skip drums that are above the main melody in the project file
-				if p.Y() < 0 {
-					return true
-				}
-
-				for line := range patternData.LineCount() {
-					for track := range patternData.TrackCount() {
-						noteValue, _ := patternData.Note(track, line)
-						if noteValue < 128 {
-							patternData.SetNote(track, line, noteValue+1)
-						}
-					}
-				}
-				return true
-			})
+			// Skip drums that are above the main melody in the project file; note commands are left as they are
+			channel.TransposePatterns(sunvoxgo.PatternFilter{Pattern: sunvoxgo.PatternsInLanes(0, math.MaxInt)}, +1)
 			fmt.Println("Song pitched up by 1 semitone")
 		case "p-":
-			channel.ForEachPattern(func(p *sunvoxgo.SunvoxPattern) bool {
-				patternData, err := p.Data()
-				if err != nil {
-					panic(err) // Should never happen
-				}
-				// Skip drums that are above the main melody in the project file
-				if p.Y() < 0 {
-					return true
-				}
-
-				for line := range patternData.LineCount() {
-					for track := range patternData.TrackCount() {
-						noteValue, _ := patternData.Note(track, line)
-						if noteValue < 128 {
-							patternData.SetNote(track, line, noteValue-1)
-						}
-					}
-				}
-				return true
-			})
+			// Skip drums that are above the main melody in the project file; note commands are left as they are
+			channel.TransposePatterns(sunvoxgo.PatternFilter{Pattern: sunvoxgo.PatternsInLanes(0, math.MaxInt)}, -1)
 			fmt.Println("Song pitched down by 1 semitone")
 
 		case "d":
@@ -0,0 +1,395 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrorNoFreeVoice = errors.New("error: no voice is free to play the note, and voice stealing is disabled")
+
+// EventTrackCount is the number of tracks events can be sent on for each SunvoxChannel (see SunvoxChannel.SendEvent()).
+// Each track plays one note at a time.
+const EventTrackCount = 16
+
+// VoiceStealing indicates which voice an Instrument cuts off to play a new note when it has no free voices.
+type VoiceStealing int
+
+const (
+	VoiceStealingOldest   VoiceStealing = iota // The voice that started playing first is stolen
+	VoiceStealingQuietest                      // The voice with the lowest velocity is stolen (or the oldest of those, if several share it)
+	VoiceStealingNone                          // No voice is stolen; NoteOn() returns ErrorNoFreeVoice instead
+)
+
+// Instrument plays notes live on a SunvoxModule, allocating each note to a free event track of the module's SunvoxChannel
+// so that notes can be turned off later without having to keep track of the tracks they're playing on.
+// Instruments on the same SunvoxChannel share its event tracks (see EventTrackCount), apart from those reserved by
+// running StepSequencers' StepTracks.
+type Instrument struct {
+	Module *SunvoxModule
+
+	// Polyphony is the maximum number of notes the Instrument can play at once; if it's less than or equal to 0 (the default),
+	// the Instrument can use every free event track.
+	Polyphony int
+
+	// Stealing indicates which voice is cut off to play a new note if the Instrument is at its polyphony limit, or if
+	// all of the SunvoxChannel's event tracks are in use. By default, it's VoiceStealingOldest.
+	Stealing VoiceStealing
+
+	// Tuning, if set, is used to play notes at the pitches it gives (through NoteCommandSetPitch) rather than at their
+	// usual pitches; notes that it doesn't map aren't played, and NoteOn() returns ErrorNoteNotMapped for them instead.
+	Tuning *Tuning
+}
+
+// NewInstrument creates a new Instrument to play notes on the given module.
+func NewInstrument(module *SunvoxModule) *Instrument {
+	return &Instrument{
+		Module: module,
+	}
+}
+
+// Voice represents a note played by an Instrument on one of its SunvoxChannel's event tracks.
+type Voice struct {
+	Instrument *Instrument
+	Track      int     // The event track the voice is playing on
+	Note       Note    // The note being played (for voices played by frequency, the closest note)
+	Frequency  float64 // The frequency the note started playing at, in Hz
+	Velocity   int     // The velocity the note was played with
+
+	started time.Time
+	active  bool // Guarded by the channel's voiceMutex
+
+	// For voices played through an EventScheduler (as by an Arpeggiator), the scheduled note on and note off, and when
+	// the note off is heard (after which the voice's track is free again); guarded by the channel's voiceMutex
+	scheduledOn  *ScheduledEvent
+	scheduledOff *ScheduledEvent
+	offTime      time.Time
+}
+
+// NoteOn starts playing the given note with the given velocity (from 1 to 129; 0 uses the default velocity),
+// returning the Voice playing it. If there's no free voice, one is stolen according to the Instrument's Stealing
+// setting; if stealing is disabled, ErrorNoFreeVoice is returned. Voices are only stolen from Instruments, so if running
+// StepSequencers have reserved all of the channel's event tracks, ErrorNoFreeVoice is returned as well.
+func (i *Instrument) NoteOn(note Note, velocity int) (*Voice, error) {
+	return i.noteOnAt(nil, time.Now(), note, velocity)
+}
+
+// noteOnAt works as NoteOn(), but if scheduler isn't nil, the note is sent through it to be heard at the given time.
+func (i *Instrument) noteOnAt(scheduler *EventScheduler, at time.Time, note Note, velocity int) (*Voice, error) {
+
+	if i.Tuning != nil && note.IsValid() {
+		frequency, ok := i.Tuning.Frequency(note)
+		if !ok {
+			return nil, ErrorNoteNotMapped
+		}
+		return i.noteOn(scheduler, at, note, FrequencyToPitch(frequency), frequency, velocity)
+	}
+
+	// The frequency is the one Sunvox plays the note at, which is slightly lower than Note.Frequency() gives
+	frequency := 0.0
+	if note.IsValid() {
+		frequency = PitchToFrequency(note.Pitch())
+	}
+
+	return i.noteOn(scheduler, at, note, -1, frequency, velocity)
+
+}
+
+// PlayFrequency starts playing a note at the given frequency in Hz (through NoteCommandSetPitch, so it isn't limited to
+// the pitches of notes) with the given velocity, returning the Voice playing it. The Instrument's Tuning isn't used.
+// See NoteOn() for more information.
+func (i *Instrument) PlayFrequency(hz float64, velocity int) (*Voice, error) {
+	note, _ := NoteFromFrequency(hz)
+	return i.noteOn(nil, time.Now(), note, FrequencyToPitch(hz), hz, velocity)
+}
+
+// noteOn starts playing the given note with the given velocity on a voice that's free at the given time. If pitch isn't
+// negative, the note is played at that Sunvox pitch value through NoteCommandSetPitch. If scheduler isn't nil, the note
+// is sent through it to be heard at the given time; otherwise, it's sent immediately.
+func (i *Instrument) noteOn(scheduler *EventScheduler, at time.Time, note Note, pitch int, frequency float64, velocity int) (*Voice, error) {
+
+	channel := i.Module.Channel
+
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+
+	track := -1
+
+	// If the Instrument is at its polyphony limit, one of its own voices has to make way
+	if i.Polyphony > 0 && len(channel.voicesFor(i, at)) >= i.Polyphony {
+		stolen := i.voiceToSteal(channel.voicesFor(i, at))
+		if stolen == nil {
+			return nil, ErrorNoFreeVoice
+		}
+		stolen.off()
+		track = stolen.Track
+	}
+
+	if track < 0 {
+		for t, voice := range channel.voices {
+			if (voice == nil || voice.endsBy(at)) && !channel.reservedTracks[t] {
+				track = t
+				break
+			}
+		}
+	}
+
+	// If all of the channel's tracks are in use, a voice from any instrument can be stolen
+	if track < 0 {
+		stolen := i.voiceToSteal(channel.voicesFor(nil, at))
+		if stolen == nil {
+			return nil, ErrorNoFreeVoice
+		}
+		stolen.off()
+		track = stolen.Track
+	}
+
+	event := TrackEvent{Track: track, Note: int(note), Velocity: velocity, Module: i.Module.Index + 1}
+	if pitch >= 0 {
+		event.Note = NoteCommandSetPitch
+		event.ControllerValue = pitch
+	}
+
+	var scheduled *ScheduledEvent
+
+	if scheduler != nil {
+		scheduled = scheduler.At(at, event)
+	} else if err := channel.SendEvent(event.Track, event.Note, event.Velocity, event.Module, 0, event.ControllerValue); err != nil {
+		return nil, errors.New(fmt.Sprintf("error playing note %s on module %d; %s", note, i.Module.Index, err.Error()))
+	}
+
+	// A voice that ends before the note starts leaves its note off scheduled as it is, but no longer holds the track
+	if previous := channel.voices[track]; previous != nil {
+		previous.active = false
+	}
+
+	voice := &Voice{
+		Instrument: i,
+		Track:      track,
+		Note:       note,
+		Frequency:  frequency,
+		Velocity:   velocity,
+		started:    at,
+		active:     true,
+
+		scheduledOn: scheduled,
+	}
+
+	channel.voices[track] = voice
+
+	return voice, nil
+
+}
+
+// PlayNote plays the given note with the given velocity, turning it off after the given duration. See NoteOn() for more
+// information.
+func (i *Instrument) PlayNote(note Note, velocity int, duration time.Duration) (*Voice, error) {
+
+	voice, err := i.NoteOn(note, velocity)
+	if err != nil {
+		return nil, err
+	}
+
+	time.AfterFunc(duration, func() { voice.Off() })
+
+	return voice, nil
+
+}
+
+// AllOff turns off all of the Instrument's voices.
+func (i *Instrument) AllOff() {
+
+	channel := i.Module.Channel
+
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+
+	for _, voice := range channel.voicesFor(i, time.Now()) {
+		voice.off()
+	}
+
+}
+
+// Voices returns the Instrument's voices that are currently playing, ordered by event track.
+func (i *Instrument) Voices() []*Voice {
+	channel := i.Module.Channel
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+	return channel.voicesFor(i, time.Now())
+}
+
+// voiceToSteal returns the voice to steal out of the given voices according to the Instrument's Stealing setting, or
+// nil if no voice should be stolen.
+func (i *Instrument) voiceToSteal(voices []*Voice) *Voice {
+
+	if i.Stealing == VoiceStealingNone {
+		return nil
+	}
+
+	// A velocity of 0 is the default velocity, which is the loudest
+	loudness := func(voice *Voice) int {
+		if voice.Velocity <= 0 {
+			return 129
+		}
+		return voice.Velocity
+	}
+
+	var stolen *Voice
+
+	for _, voice := range voices {
+
+		if stolen == nil {
+			stolen = voice
+			continue
+		}
+
+		if i.Stealing == VoiceStealingQuietest && loudness(voice) != loudness(stolen) {
+			if loudness(voice) < loudness(stolen) {
+				stolen = voice
+			}
+		} else if voice.started.Before(stolen.started) {
+			stolen = voice
+		}
+
+	}
+
+	return stolen
+
+}
+
+// Off turns off the Voice's note. If the Voice has already been turned off (or stolen to play another note), Off does
+// nothing.
+func (v *Voice) Off() {
+	channel := v.Instrument.Module.Channel
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+	v.off()
+}
+
+// SetPitch changes the pitch of the Voice's note to the given frequency in Hz, without restarting it (so it can be
+// used for vibrato or pitch bends, for example). If the Voice has already been turned off, SetPitch does nothing.
+func (v *Voice) SetPitch(hz float64) error {
+	channel := v.Instrument.Module.Channel
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+	if !v.active || v.endsBy(time.Now()) {
+		return nil
+	}
+	return channel.SendEvent(v.Track, NoteCommandSetPitch, 0, v.Instrument.Module.Index+1, 0, FrequencyToPitch(hz))
+}
+
+// IsActive returns if the Voice is still playing; that is, it hasn't been turned off or stolen.
+func (v *Voice) IsActive() bool {
+	channel := v.Instrument.Module.Channel
+	channel.voiceMutex.Lock()
+	defer channel.voiceMutex.Unlock()
+	return v.active && !v.endsBy(time.Now())
+}
+
+// off sends a note off for the Voice and frees its track. If the Voice's note was scheduled and hasn't been sent yet,
+// it's canceled instead. The channel's voiceMutex must be held.
+func (v *Voice) off() {
+
+	if !v.active || v.endsBy(time.Now()) {
+		return
+	}
+
+	channel := v.Instrument.Module.Channel
+
+	if v.scheduledOff != nil {
+		v.scheduledOff.Cancel()
+	}
+
+	if v.scheduledOn == nil || !v.scheduledOn.Cancel() {
+		channel.SendEvent(v.Track, NoteCommandNoteOff, 0, v.Instrument.Module.Index+1, 0, 0)
+	}
+
+	v.active = false
+	if channel.voices[v.Track] == v {
+		channel.voices[v.Track] = nil
+	}
+
+}
+
+// offAt schedules a note off for the Voice through the given EventScheduler to be heard at the given time; from then on,
+// its track is free to play other notes. The channel's voiceMutex must be held.
+func (v *Voice) offAt(scheduler *EventScheduler, at time.Time) {
+	if !v.active || v.scheduledOff != nil {
+		return
+	}
+	v.scheduledOff = scheduler.At(at, TrackEvent{Track: v.Track, Note: NoteCommandNoteOff, Module: v.Instrument.Module.Index + 1})
+	v.offTime = at
+}
+
+// endsBy returns if the Voice's note off is scheduled to be heard by the given time. The channel's voiceMutex must be held.
+func (v *Voice) endsBy(at time.Time) bool {
+	return !v.offTime.IsZero() && !at.Before(v.offTime)
+}
+
+// PlayFrequency starts playing a note at the given frequency in Hz on the given module with the given velocity, returning
+// the Voice playing it. This is a shortcut for creating an Instrument for the module and calling Instrument.PlayFrequency().
+func (s *SunvoxChannel) PlayFrequency(module *SunvoxModule, hz float64, velocity int) (*Voice, error) {
+	return NewInstrument(module).PlayFrequency(hz, velocity)
+}
+
+// voicesFor returns the voices of the given Instrument (or of all instruments, if it's nil) on the SunvoxChannel that are
+// still playing at the given time. voiceMutex must be held.
+func (s *SunvoxChannel) voicesFor(instrument *Instrument, at time.Time) []*Voice {
+	voices := []*Voice{}
+	for _, voice := range s.voices {
+		if voice != nil && !voice.endsBy(at) && (instrument == nil || voice.Instrument == instrument) {
+			voices = append(voices, voice)
+		}
+	}
+	return voices
+}
+
+// reserveEventTrack reserves the given event track of the SunvoxChannel so that Instruments don't play voices on it,
+// turning off any voice playing on it. If the track is reserved already (or doesn't exist), false is returned.
+func (s *SunvoxChannel) reserveEventTrack(track int) bool {
+	s.voiceMutex.Lock()
+	defer s.voiceMutex.Unlock()
+	if track < 0 || track >= EventTrackCount || s.reservedTracks[track] {
+		return false
+	}
+	s.reservedTracks[track] = true
+	if voice := s.voices[track]; voice != nil {
+		voice.off()
+	}
+	return true
+}
+
+// unreservedEventTrack returns the last of the SunvoxChannel's event tracks that isn't reserved and isn't one of the
+// given tracks, or -1 if there's none.
+func (s *SunvoxChannel) unreservedEventTrack(taken []int) int {
+	s.voiceMutex.Lock()
+	defer s.voiceMutex.Unlock()
+	for t := EventTrackCount - 1; t >= 0; t-- {
+		if !s.reservedTracks[t] && !slices.Contains(taken, t) {
+			return t
+		}
+	}
+	return -1
+}
+
+// releaseEventTrack releases an event track reserved through reserveEventTrack(), so that Instruments can use it again.
+func (s *SunvoxChannel) releaseEventTrack(track int) {
+	s.voiceMutex.Lock()
+	defer s.voiceMutex.Unlock()
+	if track >= 0 && track < EventTrackCount {
+		s.reservedTracks[track] = false
+	}
+}
+
+// clearVoices forgets all of the SunvoxChannel's voices without sending note offs, as when the slot is reopened.
+func (s *SunvoxChannel) clearVoices() {
+	s.voiceMutex.Lock()
+	defer s.voiceMutex.Unlock()
+	for t, voice := range s.voices {
+		if voice != nil {
+			voice.active = false
+			s.voices[t] = nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sunvoxgo
+
+import (
+	"iter"
+)
+
+// Patterns returns an iterator over the SunvoxChannel's patterns, ordered by index. Sunvox keeps a slot for each pattern
+// that has been created, and the slots of removed patterns are left empty (so pattern indices aren't necessarily
+// contiguous); empty slots are skipped. If any filters are given, only the patterns that all of them return true for
+// are yielded (see PatternsInLanes() and PatternsInLines() for some).
+func (s *SunvoxChannel) Patterns(filters ...func(pattern *SunvoxPattern) bool) iter.Seq[*SunvoxPattern] {
+	return func(yield func(*SunvoxPattern) bool) {
+
+		slotCount := int(getNumberOfPatternSlots(s.Index))
+
+		for i := 0; i < slotCount; i++ {
+
+			if getPatternLineCount(s.Index, i) <= 0 {
+				continue
+			}
+
+			pattern := &SunvoxPattern{Channel: s, Index: i}
+
+			if matchesAll(pattern, filters) && !yield(pattern) {
+				return
+			}
+
+		}
+
+	}
+}
+
+// Modules returns an iterator over the SunvoxChannel's modules (including the Output module), ordered by index. Like
+// patterns, the slots of removed modules are left empty, and are skipped. If any filters are given, only the modules that
+// all of them return true for are yielded (see ModulesWithFlags() for one).
+func (s *SunvoxChannel) Modules(filters ...func(module *SunvoxModule) bool) iter.Seq[*SunvoxModule] {
+	return func(yield func(*SunvoxModule) bool) {
+
+		slotCount := int(getNumberOfModuleSlots(s.Index))
+
+		for i := 0; i < slotCount; i++ {
+
+			if flags := getModuleFlags(s.Index, i); flags < 0 || flags&ModuleFlagExists == 0 {
+				continue
+			}
+
+			module := &SunvoxModule{Channel: s, Index: i}
+
+			if matchesAll(module, filters) && !yield(module) {
+				return
+			}
+
+		}
+
+	}
+}
+
+// Channels returns an iterator over the engine's created channels, ordered by index. If any filters are given, only the
+// channels that all of them return true for are yielded.
+func (e *SunvoxEngine) Channels(filters ...func(channel *SunvoxChannel) bool) iter.Seq[*SunvoxChannel] {
+	return func(yield func(*SunvoxChannel) bool) {
+		for _, channel := range e.channelList() {
+			if matchesAll(channel, filters) && !yield(channel) {
+				return
+			}
+		}
+	}
+}
+
+// Cells returns an iterator over the cells of the SunvoxPatternData that the filter selects, ordered by line and then by
+// track. Unlike Find(), empty cells are included (unless the filter's Match function leaves them out). The cells are
+// copies, so changing them doesn't change the pattern data.
+func (s SunvoxPatternData) Cells(filter PatternFilter) iter.Seq[PatternCell] {
+	return func(yield func(PatternCell) bool) {
+		track, line, trackEnd, lineEnd := filter.Region.bounds(s)
+		for l := line; l < lineEnd; l++ {
+			for t := track; t < trackEnd; t++ {
+				cell := s.Data[t+l*s.trackCount]
+				if !filter.selects(cell) {
+					continue
+				}
+				if !yield(PatternCell{Pattern: s.pattern, Track: t, Line: l, SunvoxPatternNoteData: cell}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// PatternsInLines returns a filter for SunvoxChannel.Patterns() (or PatternFilter.Pattern) that selects the patterns
+// that overlap the lines from startLine up to (but not including) endLine.
+func PatternsInLines(startLine, endLine int) func(pattern *SunvoxPattern) bool {
+	return func(pattern *SunvoxPattern) bool {
+		lineCount, err := pattern.LineCount()
+		if err != nil {
+			return false
+		}
+		x := pattern.X()
+		return x < endLine && x+lineCount > startLine
+	}
+}
+
+// ModulesWithFlags returns a filter for SunvoxChannel.Modules() that selects the modules that have all of the given
+// flags set (for example, ModuleFlagGenerator for instruments, or ModuleFlagEffect for effects).
+func ModulesWithFlags(flags int32) func(module *SunvoxModule) bool {
+	return func(module *SunvoxModule) bool {
+		moduleFlags, err := module.Flags()
+		return err == nil && moduleFlags&flags == flags
+	}
+}
+
+// matchesAll returns if all of the given filters return true for the value.
+func matchesAll[T any](value T, filters []func(T) bool) bool {
+	for _, filter := range filters {
+		if !filter(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,595 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+var ErrorNothingToUndo = errors.New("error: there's nothing to undo")
+var ErrorNothingToRedo = errors.New("error: there's nothing to redo")
+var ErrorEditStepOpen = errors.New("error: an edit step is still open")
+var ErrorNoEditStep = errors.New("error: no edit step is open")
+
+// journalEditSize is the approximate amount of memory a recorded edit takes up before counting the values it holds on to,
+// in bytes.
+const journalEditSize = 64
+
+// journalEdit is a recorded change that can be reverted.
+type journalEdit struct {
+	revert func() (journalEdit, error) // Reverts the change, returning the edit that reverts it in turn (so that it can be redone)
+	size   int                         // The approximate amount of memory the edit takes up, in bytes
+}
+
+// journalStep is a named group of edits that are undone and redone together.
+type journalStep struct {
+	name  string
+	edits []journalEdit
+}
+
+// size returns the approximate amount of memory the step's edits take up, in bytes.
+func (s journalStep) size() int {
+	size := 0
+	for _, edit := range s.edits {
+		size += edit.size
+	}
+	return size
+}
+
+// editJournal holds a SunvoxChannel's undo and redo history. It's guarded by the channel's journalMutex.
+type editJournal struct {
+	memoryLimit int
+	undo        []journalStep
+	redo        []journalStep
+	open        journalStep
+	depth       int // How many edit steps are open (as they can be nested)
+	size        int
+}
+
+// EnableJournal starts recording the edits made to the SunvoxChannel's project, so that they can be undone and redone
+// (see Undo() and Redo()). The journal keeps to the given memory limit in bytes (approximately) by forgetting the oldest
+// steps once it's reached, though the most recent step is always kept; if memoryLimit is less than or equal to 0, the
+// journal's memory isn't limited. If the journal is already enabled, only its memory limit is changed.
+//
+// The edits recorded are changes to pattern data (through SunvoxPatternData's functions, SunvoxPattern.SetEvent() and
+// SunvoxPattern.Commit(), but not writes to SunvoxPatternData.Data itself), pattern positions and muting, module
+// controllers, finetunes, relative notes, connections and BSM flags, the project's name, and the channel's loop regions
+// (including custom loops). Changes made gradually by tweens and fades aren't recorded, nor are playback changes (like
+// SetBPM()).
+//
+// The journal is cleared whenever a project is loaded into the SunvoxChannel.
+func (s *SunvoxChannel) EnableJournal(memoryLimit int) {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	s.journal.memoryLimit = memoryLimit
+	s.journal.trim()
+	s.journalEnabled.Store(true)
+}
+
+// DisableJournal stops recording edits made to the SunvoxChannel's project, and clears its undo and redo history.
+func (s *SunvoxChannel) DisableJournal() {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	s.journalEnabled.Store(false)
+	s.journal = editJournal{}
+}
+
+// IsJournalEnabled returns if edits made to the SunvoxChannel's project are being recorded (see EnableJournal()).
+func (s *SunvoxChannel) IsJournalEnabled() bool {
+	return s.journalEnabled.Load()
+}
+
+// ClearJournal clears the SunvoxChannel's undo and redo history, leaving the journal enabled (if it is).
+func (s *SunvoxChannel) ClearJournal() {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	s.journal.undo = nil
+	s.journal.redo = nil
+	s.journal.open = journalStep{name: s.journal.open.name}
+	s.journal.size = 0
+}
+
+// BeginEditStep starts grouping the edits made to the SunvoxChannel into a step with the given name, so that they're
+// undone and redone together, until EndEditStep() is called. Steps can be nested, in which case the edits are grouped
+// into the outermost step (and the inner steps' names are ignored). If name is empty, the step is named after its first
+// edit (like "Move pattern").
+//
+// Edits made outside of any step are each recorded as a step of their own. Functions that make many edits at once (like
+// SunvoxChannel.TransposePatterns() or SunvoxChannel.Batch()) group them into a step automatically.
+func (s *SunvoxChannel) BeginEditStep(name string) {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	s.journal.begin(name)
+}
+
+// EndEditStep ends the edit step started with BeginEditStep(). Once the outermost step is ended, it's added to the undo
+// history (if any edits were recorded in it).
+func (s *SunvoxChannel) EndEditStep() {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	s.journal.end()
+}
+
+// EditStep calls the given function within an edit step of the given name (see BeginEditStep()), ending the step
+// afterwards even if the function returns an error or panics. It returns the error returned by the function.
+func (s *SunvoxChannel) EditStep(name string, edit func() error) error {
+	s.BeginEditStep(name)
+	defer s.EndEditStep()
+	return edit()
+}
+
+// CancelEditStep reverts the edits made since the outermost open edit step began and ends it (and any steps nested
+// within it) without adding it to the undo history. This is useful for temporary changes (like setting a custom loop
+// while previewing part of a song): begin a step, make the changes, and cancel it when they're no longer needed.
+// If no step is open, ErrorNoEditStep is returned.
+func (s *SunvoxChannel) CancelEditStep() error {
+
+	s.journalMutex.Lock()
+	step, err := s.journal.cancel()
+	s.journalMutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.revertStep(step)
+
+	return err
+
+}
+
+// Undo reverts the most recent step in the SunvoxChannel's undo history, moving it to the redo history, and returns its
+// name. If there's nothing to undo, ErrorNothingToUndo is returned; if an edit step is open, ErrorEditStepOpen is
+// returned. If any of the step's edits can't be reverted (for example, because the pattern it was made to no longer
+// exists), the rest are still reverted, and the first error is returned.
+func (s *SunvoxChannel) Undo() (string, error) {
+	return s.journal.undoStep(&s.journalMutex, &s.journalEnabled, s.revertStep)
+}
+
+// Redo reapplies the most recently undone step, moving it back to the undo history, and returns its name. If there's
+// nothing to redo, ErrorNothingToRedo is returned; if an edit step is open, ErrorEditStepOpen is returned. Recording a
+// new edit clears the redo history.
+func (s *SunvoxChannel) Redo() (string, error) {
+	return s.journal.redoStep(&s.journalMutex, &s.journalEnabled, s.revertStep)
+}
+
+// UndoName returns the name of the step that Undo() would revert, and whether there is one.
+func (s *SunvoxChannel) UndoName() (string, bool) {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	if len(s.journal.undo) == 0 {
+		return "", false
+	}
+	return s.journal.undo[len(s.journal.undo)-1].name, true
+}
+
+// RedoName returns the name of the step that Redo() would reapply, and whether there is one.
+func (s *SunvoxChannel) RedoName() (string, bool) {
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+	if len(s.journal.redo) == 0 {
+		return "", false
+	}
+	return s.journal.redo[len(s.journal.redo)-1].name, true
+}
+
+// recordEdit records the given edit in the SunvoxChannel's journal (if it's enabled), as part of the open edit step or
+// as a step of its own with the given name. Recording an edit clears the redo history.
+func (s *SunvoxChannel) recordEdit(name string, edit journalEdit) {
+
+	if !s.journalEnabled.Load() {
+		return
+	}
+
+	s.journalMutex.Lock()
+	defer s.journalMutex.Unlock()
+
+	s.journal.record(name, edit)
+
+}
+
+// undoStep reverts the most recent step in the undo history through revert, moving it to the redo history, and returns
+// its name; see SunvoxChannel.Undo(). mutex is the one guarding the journal; if enabled is false once the step has been
+// reverted (as the journal was disabled in the meantime), the step isn't moved to the redo history.
+func (j *editJournal) undoStep(mutex *sync.Mutex, enabled *atomic.Bool, revert func(step journalStep) (journalStep, error)) (string, error) {
+	return j.moveStep(mutex, enabled, revert, j.takeUndo, j.pushRedo)
+}
+
+// redoStep reapplies the most recently undone step through revert, moving it back to the undo history, and returns its
+// name; see SunvoxChannel.Redo() and undoStep().
+func (j *editJournal) redoStep(mutex *sync.Mutex, enabled *atomic.Bool, revert func(step journalStep) (journalStep, error)) (string, error) {
+	return j.moveStep(mutex, enabled, revert, j.takeRedo, j.pushUndo)
+}
+
+// moveStep takes a step from one history, reverts it, and pushes the step that reverts it in turn onto the other.
+func (j *editJournal) moveStep(mutex *sync.Mutex, enabled *atomic.Bool, revert func(step journalStep) (journalStep, error), take func() (journalStep, error), push func(step journalStep)) (string, error) {
+
+	mutex.Lock()
+	step, err := take()
+	// The edits are reverted without holding the mutex, as reverting can involve other locks that are held while edits
+	// are recorded
+	mutex.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	inverse, err := revert(step)
+
+	mutex.Lock()
+	if enabled.Load() {
+		push(inverse)
+	}
+	mutex.Unlock()
+
+	return step.name, err
+
+}
+
+// revertStep reverts the edits of the given step while the SunvoxChannel is locked, returning the step that reverts
+// it in turn, along with the first error encountered.
+func (s *SunvoxChannel) revertStep(step journalStep) (journalStep, error) {
+	s.Lock()
+	defer s.Unlock()
+	return step.revert()
+}
+
+// revert reverts the step's edits (from the last to the first), returning the step that reverts it in turn, along
+// with the first error encountered. If any of the edits can't be reverted, the rest are still reverted.
+func (s journalStep) revert() (journalStep, error) {
+
+	inverse := journalStep{name: s.name, edits: make([]journalEdit, 0, len(s.edits))}
+
+	var firstErr error
+
+	for i := len(s.edits) - 1; i >= 0; i-- {
+		edit, err := s.edits[i].revert()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		// The inverse edits are added in the order they're made, so that reverting them from the last to the first
+		// applies them in their original order
+		inverse.edits = append(inverse.edits, edit)
+	}
+
+	return inverse, firstErr
+
+}
+
+// begin opens an edit step of the given name (see SunvoxChannel.BeginEditStep()).
+func (j *editJournal) begin(name string) {
+	if j.depth == 0 {
+		j.open = journalStep{name: name}
+	}
+	j.depth++
+}
+
+// end ends the innermost open edit step, adding the open step to the undo history once the outermost one is ended.
+func (j *editJournal) end() {
+
+	if j.depth == 0 {
+		return
+	}
+
+	j.depth--
+
+	if j.depth == 0 {
+		if len(j.open.edits) > 0 {
+			j.undo = append(j.undo, j.open)
+			j.trim()
+		}
+		j.open = journalStep{}
+	}
+
+}
+
+// cancel ends all open edit steps without adding the open step to the undo history, returning it so that it can be
+// reverted.
+func (j *editJournal) cancel() (journalStep, error) {
+	if j.depth == 0 {
+		return journalStep{}, ErrorNoEditStep
+	}
+	step := j.open
+	j.open = journalStep{}
+	j.depth = 0
+	j.size -= step.size()
+	return step, nil
+}
+
+// record adds the edit to the open edit step, or as a step of its own with the given name if no step is open.
+// Recording an edit clears the redo history.
+func (j *editJournal) record(name string, edit journalEdit) {
+
+	for _, step := range j.redo {
+		j.size -= step.size()
+	}
+	j.redo = nil
+
+	j.size += edit.size
+
+	if j.depth > 0 {
+		if j.open.name == "" {
+			j.open.name = name
+		}
+		j.open.edits = append(j.open.edits, edit)
+		return
+	}
+
+	j.undo = append(j.undo, journalStep{name: name, edits: []journalEdit{edit}})
+	j.trim()
+
+}
+
+// takeUndo removes the most recent step from the undo history and returns it.
+func (j *editJournal) takeUndo() (journalStep, error) {
+	if j.depth > 0 {
+		return journalStep{}, ErrorEditStepOpen
+	}
+	if len(j.undo) == 0 {
+		return journalStep{}, ErrorNothingToUndo
+	}
+	step := j.undo[len(j.undo)-1]
+	j.undo = j.undo[:len(j.undo)-1]
+	j.size -= step.size()
+	return step, nil
+}
+
+// takeRedo removes the most recent step from the redo history and returns it.
+func (j *editJournal) takeRedo() (journalStep, error) {
+	if j.depth > 0 {
+		return journalStep{}, ErrorEditStepOpen
+	}
+	if len(j.redo) == 0 {
+		return journalStep{}, ErrorNothingToRedo
+	}
+	step := j.redo[len(j.redo)-1]
+	j.redo = j.redo[:len(j.redo)-1]
+	j.size -= step.size()
+	return step, nil
+}
+
+// pushUndo adds the step to the undo history without clearing the redo history (as when a step is redone).
+func (j *editJournal) pushUndo(step journalStep) {
+	j.undo = append(j.undo, step)
+	j.size += step.size()
+	j.trim()
+}
+
+// pushRedo adds the step to the redo history (as when a step is undone).
+func (j *editJournal) pushRedo(step journalStep) {
+	j.redo = append(j.redo, step)
+	j.size += step.size()
+	j.trim()
+}
+
+// trim forgets the oldest steps until the journal is within its memory limit, starting with the undo history and
+// then the redo history, always keeping the most recent step.
+func (j *editJournal) trim() {
+
+	if j.memoryLimit <= 0 {
+		return
+	}
+
+	for j.size > j.memoryLimit && len(j.undo)+len(j.redo) > 1 {
+		if len(j.undo) > 1 || (len(j.undo) == 1 && len(j.redo) > 0) {
+			j.size -= j.undo[0].size()
+			j.undo = j.undo[1:]
+		} else {
+			// The oldest redo step is the one furthest from the present (the first to be undone)
+			j.size -= j.redo[0].size()
+			j.redo = j.redo[1:]
+		}
+	}
+
+}
+
+// eventEdit returns an edit that restores the cell at the given track and line of the pattern to the given value.
+func eventEdit(pattern *SunvoxPattern, track, line int, cell SunvoxPatternNoteData) journalEdit {
+	return journalEdit{
+		size: journalEditSize + int(unsafe.Sizeof(cell)),
+		revert: func() (journalEdit, error) {
+			current, err := pattern.event(track, line)
+			if err != nil {
+				return journalEdit{}, err
+			}
+			if err := pattern.writeEvent(track, line, cell); err != nil {
+				return journalEdit{}, err
+			}
+			return eventEdit(pattern, track, line, current), nil
+		},
+	}
+}
+
+// patternXYEdit returns an edit that moves the pattern back to the given position.
+func patternXYEdit(pattern *SunvoxPattern, x, y int) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			inverse := patternXYEdit(pattern, pattern.X(), pattern.Y())
+			res := setPatternXY(pattern.Channel.Index, pattern.Index, x, y)
+			pattern.Channel.invalidateMarkers()
+			if res != 0 {
+				return journalEdit{}, errors.New(fmt.Sprintf("error reverting pattern %d's position to %d, %d; error code %d", pattern.Index, x, y, res))
+			}
+			return inverse, nil
+		},
+	}
+}
+
+// patternMuteEdit returns an edit that sets whether the pattern is muted back to the given value.
+func patternMuteEdit(pattern *SunvoxPattern, muted bool) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			previous, err := pattern.setMute(muted)
+			if err != nil {
+				return journalEdit{}, err
+			}
+			return patternMuteEdit(pattern, previous), nil
+		},
+	}
+}
+
+// controllerEdit returns an edit that sets the module's controller back from the value it was set to (to) to the value
+// it had before (from). Both values are kept, as controller changes are applied by the audio engine asynchronously, so
+// the controller's current value can't be relied on right after it's been set.
+func controllerEdit(module *SunvoxModule, ctrlNum, from, to int) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			if err := module.setControllerValue(ctrlNum, from); err != nil {
+				return journalEdit{}, err
+			}
+			return controllerEdit(module, ctrlNum, to, from), nil
+		},
+	}
+}
+
+// connectionEdit returns an edit that connects or disconnects the source module from the destination module.
+func connectionEdit(source, dest *SunvoxModule, connected bool) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			var res int32
+			if connected {
+				res = connectModule(source.Channel.Index, source.Index, dest.Index)
+			} else {
+				res = disconnectModule(source.Channel.Index, source.Index, dest.Index)
+			}
+			if res < 0 {
+				return journalEdit{}, errors.New(fmt.Sprintf("error reverting connection from module %d to module %d; error code %d", source.Index, dest.Index, res))
+			}
+			return connectionEdit(source, dest, !connected), nil
+		},
+	}
+}
+
+// finetuneEdit returns an edit that sets the module's finetune back to the given value.
+func finetuneEdit(module *SunvoxModule, finetune int) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			inverse := finetuneEdit(module, int(int32(module.Finetune())))
+			if err := module.setFinetune(finetune); err != nil {
+				return journalEdit{}, err
+			}
+			return inverse, nil
+		},
+	}
+}
+
+// relativeNoteEdit returns an edit that sets the module's relative note back to the given value.
+func relativeNoteEdit(module *SunvoxModule, relativeNote int) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			inverse := relativeNoteEdit(module, int(int32(module.RelativeNote())))
+			if err := module.setRelativeNote(relativeNote); err != nil {
+				return journalEdit{}, err
+			}
+			return inverse, nil
+		},
+	}
+}
+
+// bsmState holds a module's bypass, solo and mute flags.
+type bsmState struct {
+	bypass, solo, mute bool
+}
+
+// bsmEdit returns an edit that sets the module's bypass, solo and mute flags back from the values they were set to (to)
+// to the values they had before (from). Like with controllerEdit(), both are kept, as the flags are changed
+// asynchronously by sending an event, so they can't be read back right after being set.
+func bsmEdit(module *SunvoxModule, from, to bsmState) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			if err := module.setBSM(from.bypass, from.solo, from.mute); err != nil {
+				return journalEdit{}, err
+			}
+			return bsmEdit(module, to, from), nil
+		},
+	}
+}
+
+// projectNameEdit returns an edit that sets the project's name back to the given name.
+func projectNameEdit(channel *SunvoxChannel, name string) journalEdit {
+	return journalEdit{
+		size: journalEditSize + len(name),
+		revert: func() (journalEdit, error) {
+			inverse := projectNameEdit(channel, channel.ProjectName())
+			if res := setSongName(channel.Index, name); res != 0 {
+				return journalEdit{}, errors.New(fmt.Sprintf("error reverting the project name in channel index %d; error code %d", channel.Index, res))
+			}
+			return inverse, nil
+		},
+	}
+}
+
+// loopRegionState holds the state of a SunvoxChannel's loop regions.
+type loopRegionState struct {
+	regions         map[string]LoopRegion
+	activeRegion    string
+	pendingRegion   string
+	switchingRegion bool
+}
+
+// size returns the approximate amount of memory the state takes up, in bytes.
+func (l loopRegionState) size() int {
+	size := len(l.activeRegion) + len(l.pendingRegion)
+	for name := range l.regions {
+		// Each region's name is held both as its key and in the region itself, though the string data is shared
+		size += len(name) + int(unsafe.Sizeof(name)+unsafe.Sizeof(LoopRegion{}))
+	}
+	return size
+}
+
+// recordLoopRegions records the current state of the SunvoxChannel's loop regions in its journal before they're
+// changed. regionMutex must be held.
+func (s *SunvoxChannel) recordLoopRegions() {
+	if !s.journalEnabled.Load() {
+		return
+	}
+	s.recordEdit("Change loop regions", loopRegionEdit(s, loopRegionState{
+		regions:         maps.Clone(s.regions),
+		activeRegion:    s.activeRegion,
+		pendingRegion:   s.pendingRegion,
+		switchingRegion: s.switchingRegion,
+	}))
+}
+
+// loopRegionEdit returns an edit that restores the channel's loop regions to the given state. As the whole state is
+// held, the edit is charged for all of the regions in it.
+func loopRegionEdit(channel *SunvoxChannel, state loopRegionState) journalEdit {
+	return journalEdit{
+		size: journalEditSize + state.size(),
+		revert: func() (journalEdit, error) {
+
+			channel.regionMutex.Lock()
+			defer channel.regionMutex.Unlock()
+
+			inverse := loopRegionEdit(channel, loopRegionState{
+				regions:         channel.regions,
+				activeRegion:    channel.activeRegion,
+				pendingRegion:   channel.pendingRegion,
+				switchingRegion: channel.switchingRegion,
+			})
+
+			channel.regions = maps.Clone(state.regions)
+			channel.activeRegion = state.activeRegion
+			channel.pendingRegion = state.pendingRegion
+			channel.switchingRegion = state.switchingRegion
+
+			engine.startPolling()
+
+			return inverse, nil
+
+		},
+	}
+}
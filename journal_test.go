@@ -0,0 +1,322 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// valueEdit returns a synthetic edit that sets the value back to the given one.
+func valueEdit(value *int, to, size int) journalEdit {
+	return journalEdit{
+		size: size,
+		revert: func() (journalEdit, error) {
+			inverse := valueEdit(value, *value, size)
+			*value = to
+			return inverse, nil
+		},
+	}
+}
+
+// logEdit returns a synthetic edit that appends its name to the log whenever it (or its inverse) is reverted.
+func logEdit(log *[]string, name string) journalEdit {
+	return journalEdit{
+		size: journalEditSize,
+		revert: func() (journalEdit, error) {
+			*log = append(*log, name)
+			return logEdit(log, name), nil
+		},
+	}
+}
+
+// testUndo and testRedo undo and redo through editJournal.undoStep() and editJournal.redoStep() as SunvoxChannel.Undo()
+// and SunvoxChannel.Redo() do, with the journal enabled, reverting steps without locking a channel.
+func (j *editJournal) testUndo() (string, error) {
+	var mutex sync.Mutex
+	var enabled atomic.Bool
+	enabled.Store(true)
+	return j.undoStep(&mutex, &enabled, journalStep.revert)
+}
+
+func (j *editJournal) testRedo() (string, error) {
+	var mutex sync.Mutex
+	var enabled atomic.Bool
+	enabled.Store(true)
+	return j.redoStep(&mutex, &enabled, journalStep.revert)
+}
+
+func TestJournalUndoRedo(t *testing.T) {
+
+	type op struct {
+		action   string // "set", "undo" or "redo"
+		value    int    // The value to set for "set"
+		want     int    // The value afterwards
+		wantName string
+		wantErr  error
+	}
+
+	tests := []struct {
+		name string
+		ops  []op
+	}{
+		{
+			name: "undo and redo in order",
+			ops: []op{
+				{action: "set", value: 1, want: 1},
+				{action: "set", value: 2, want: 2},
+				{action: "set", value: 3, want: 3},
+				{action: "undo", want: 2, wantName: "set 3"},
+				{action: "undo", want: 1, wantName: "set 2"},
+				{action: "redo", want: 2, wantName: "set 2"},
+				{action: "redo", want: 3, wantName: "set 3"},
+				{action: "redo", want: 3, wantErr: ErrorNothingToRedo},
+			},
+		},
+		{
+			name: "undo everything",
+			ops: []op{
+				{action: "set", value: 5, want: 5},
+				{action: "undo", want: 0, wantName: "set 5"},
+				{action: "undo", want: 0, wantErr: ErrorNothingToUndo},
+				{action: "redo", want: 5, wantName: "set 5"},
+			},
+		},
+		{
+			name: "recording clears redo",
+			ops: []op{
+				{action: "set", value: 1, want: 1},
+				{action: "set", value: 2, want: 2},
+				{action: "undo", want: 1, wantName: "set 2"},
+				{action: "set", value: 7, want: 7},
+				{action: "redo", want: 7, wantErr: ErrorNothingToRedo},
+				{action: "undo", want: 1, wantName: "set 7"},
+				{action: "undo", want: 0, wantName: "set 1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			j := &editJournal{}
+			value := 0
+
+			for i, o := range test.ops {
+
+				var name string
+				var err error
+
+				switch o.action {
+				case "set":
+					previous := value
+					value = o.value
+					j.record(setName(o.value), valueEdit(&value, previous, journalEditSize))
+				case "undo":
+					name, err = j.testUndo()
+				case "redo":
+					name, err = j.testRedo()
+				}
+
+				if !errors.Is(err, o.wantErr) {
+					t.Fatalf("op %d (%s): got error %v, want %v", i, o.action, err, o.wantErr)
+				}
+				if name != o.wantName {
+					t.Errorf("op %d (%s): got step name %q, want %q", i, o.action, name, o.wantName)
+				}
+				if value != o.want {
+					t.Errorf("op %d (%s): got value %d, want %d", i, o.action, value, o.want)
+				}
+
+			}
+
+		})
+	}
+
+}
+
+func setName(value int) string {
+	return "set " + strconv.Itoa(value)
+}
+
+func TestJournalStepOrder(t *testing.T) {
+
+	j := &editJournal{}
+	log := []string{}
+
+	j.begin("outer")
+	j.record("a", logEdit(&log, "a"))
+	j.begin("inner")
+	j.record("b", logEdit(&log, "b"))
+	j.end()
+	j.record("c", logEdit(&log, "c"))
+	j.end()
+
+	if len(j.undo) != 1 || j.undo[0].name != "outer" {
+		t.Fatalf("got undo history %v, want a single step named \"outer\"", j.undo)
+	}
+
+	if _, err := j.testUndo(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c", "b", "a"}; !slices.Equal(log, want) {
+		t.Errorf("undo reverted edits in order %v, want %v", log, want)
+	}
+
+	log = log[:0]
+
+	if _, err := j.testRedo(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(log, want) {
+		t.Errorf("redo reapplied edits in order %v, want %v", log, want)
+	}
+
+}
+
+func TestJournalUnnamedStep(t *testing.T) {
+	j := &editJournal{}
+	value := 0
+	j.begin("")
+	j.record("Move pattern", valueEdit(&value, 0, journalEditSize))
+	j.record("Set controller", valueEdit(&value, 0, journalEditSize))
+	j.end()
+	if name := j.undo[0].name; name != "Move pattern" {
+		t.Errorf("got step name %q, want it named after its first edit", name)
+	}
+}
+
+func TestJournalEditStepOpen(t *testing.T) {
+
+	j := &editJournal{}
+	value := 0
+
+	j.record("set", valueEdit(&value, 0, journalEditSize))
+	j.begin("open")
+
+	if _, err := j.takeUndo(); !errors.Is(err, ErrorEditStepOpen) {
+		t.Errorf("got error %v undoing with a step open, want ErrorEditStepOpen", err)
+	}
+
+	j.end()
+
+	if _, err := j.cancel(); !errors.Is(err, ErrorNoEditStep) {
+		t.Errorf("got error %v canceling without a step open, want ErrorNoEditStep", err)
+	}
+
+}
+
+func TestJournalCancel(t *testing.T) {
+
+	j := &editJournal{}
+	value := 0
+
+	j.record("set", valueEdit(&value, 0, 10))
+	j.begin("temporary")
+	j.record("set", valueEdit(&value, 0, 20))
+	j.record("set", valueEdit(&value, 0, 30))
+
+	step, err := j.cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(step.edits) != 2 {
+		t.Errorf("got %d canceled edits, want 2", len(step.edits))
+	}
+	if j.size != 10 {
+		t.Errorf("got size %d after canceling, want 10", j.size)
+	}
+	if j.depth != 0 || len(j.undo) != 1 {
+		t.Errorf("got depth %d and %d undo steps after canceling, want 0 and 1", j.depth, len(j.undo))
+	}
+
+}
+
+func TestJournalTrim(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		limit     int
+		sizes     []int // The size of each recorded step
+		undos     int   // How many steps to undo afterwards
+		newLimit  int   // If set, the memory limit is lowered to this afterwards (as with EnableJournal())
+		wantUndo  int
+		wantRedo  int
+		wantSize  int
+		wantFirst int // The size of the oldest undo step left
+	}{
+		{name: "unlimited", limit: 0, sizes: []int{100, 100, 100}, wantUndo: 3, wantSize: 300, wantFirst: 100},
+		{name: "within limit", limit: 300, sizes: []int{100, 100, 100}, wantUndo: 3, wantSize: 300, wantFirst: 100},
+		{name: "oldest forgotten", limit: 250, sizes: []int{100, 100, 100}, wantUndo: 2, wantSize: 200, wantFirst: 100},
+		{name: "real sizes", limit: 250, sizes: []int{200, 10, 20, 30}, wantUndo: 3, wantSize: 60, wantFirst: 10},
+		{name: "most recent kept", limit: 50, sizes: []int{10, 500}, wantUndo: 1, wantSize: 500, wantFirst: 500},
+		{name: "undo and redo within limit", limit: 250, sizes: []int{100, 100, 100}, undos: 1, wantUndo: 1, wantRedo: 1, wantSize: 200, wantFirst: 100},
+		{name: "redo forgotten last", sizes: []int{100, 100, 100}, undos: 2, newLimit: 150, wantUndo: 0, wantRedo: 1, wantSize: 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			j := &editJournal{memoryLimit: test.limit}
+			value := 0
+
+			for i, size := range test.sizes {
+				j.record(setName(i), valueEdit(&value, 0, size))
+			}
+
+			for i := 0; i < test.undos; i++ {
+				if _, err := j.testUndo(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if test.newLimit > 0 {
+				j.memoryLimit = test.newLimit
+				j.trim()
+			}
+
+			if len(j.undo) != test.wantUndo || len(j.redo) != test.wantRedo {
+				t.Errorf("got %d undo and %d redo steps, want %d and %d", len(j.undo), len(j.redo), test.wantUndo, test.wantRedo)
+			}
+			if j.size != test.wantSize {
+				t.Errorf("got size %d, want %d", j.size, test.wantSize)
+			}
+			if len(j.undo) > 0 && j.undo[0].size() != test.wantFirst {
+				t.Errorf("got oldest undo step of size %d, want %d", j.undo[0].size(), test.wantFirst)
+			}
+
+		})
+	}
+
+}
+
+func TestJournalDisabledWhileReverting(t *testing.T) {
+
+	j := &editJournal{}
+	value := 0
+
+	var mutex sync.Mutex
+	var enabled atomic.Bool
+	enabled.Store(true)
+
+	j.record("set 1", valueEdit(&value, 0, journalEditSize))
+	value = 1
+
+	// The journal is disabled while the step is being reverted, so the step isn't moved to the redo history
+	name, err := j.undoStep(&mutex, &enabled, func(step journalStep) (journalStep, error) {
+		enabled.Store(false)
+		return step.revert()
+	})
+
+	if err != nil || name != "set 1" || value != 0 {
+		t.Fatalf("got %q, %v, value %d; want \"set 1\", no error, value 0", name, err, value)
+	}
+
+	if len(j.redo) != 0 {
+		t.Errorf("got %d steps in the redo history, want none", len(j.redo))
+	}
+
+}
@@ -0,0 +1,128 @@
+package sunvoxgo
+
+// PlayLoops plays the song in the SunvoxChannel from the beginning, looping it so it's played the given number of times
+// in total (so a loopCount of 3 plays the song three times). Once the last pass finishes, then is called (if it isn't nil);
+// this can be used to stop other things, continue to an outro, or start another song. If then is nil, playback simply
+// ends after the last pass. If loopCount is less than or equal to 0, the song loops forever.
+//
+// This works with loop regions as well (see SetActiveLoopRegion()); in that case, playback stops at the end of the region
+// on the last pass. Use LoopCount() to get the current loop iteration.
+// See SetLoopLimit() for more information.
+func (s *SunvoxChannel) PlayLoops(loopCount int, then func()) error {
+	if err := s.PlayFromBeginning(); err != nil {
+		return err
+	}
+
+	s.loopMutex.Lock()
+	s.loopCount = 0
+	s.loopMutex.Unlock()
+
+	return s.SetLoopLimit(loopCount, then)
+}
+
+// SetLoopLimit sets the total number of times the song playing in the SunvoxChannel should be played before playback
+// ends (counting passes played since playback was last started, including the current one). Once the last pass
+// finishes, then is called (if it isn't nil). If loopLimit is less than or equal to 0, the limit is removed and the
+// song's looping setting is restored.
+//
+// Loops are detected by the engine's poller (see SunvoxEngine.SetUpdateMode()), and then is called from it. To end
+// playback precisely, looping is turned off for the last pass so Sunvox stops at the end of the song by itself; the
+// previous looping setting is restored afterwards. Stopping playback removes the limit.
+func (s *SunvoxChannel) SetLoopLimit(loopLimit int, then func()) error {
+
+	s.loopMutex.Lock()
+	defer s.loopMutex.Unlock()
+
+	if loopLimit <= 0 {
+		return s.clearLoopLimit()
+	}
+
+	if s.loopLimit <= 0 {
+		s.loopRestoreLooping = s.IsLooping()
+	}
+
+	s.loopLimit = loopLimit
+	s.loopThen = then
+
+	engine.startPolling()
+
+	return s.applyLoopLimit()
+
+}
+
+// LoopLimit returns the number of times the song is set to be played through SetLoopLimit() or PlayLoops(), or 0 if
+// there's no limit.
+func (s *SunvoxChannel) LoopLimit() int {
+	s.loopMutex.Lock()
+	defer s.loopMutex.Unlock()
+	return s.loopLimit
+}
+
+// LoopCount returns the number of times the song (or custom loop) has looped since playback was last started, so it's
+// 0 during the first pass, 1 during the second, and so on.
+func (s *SunvoxChannel) LoopCount() int {
+	s.loopMutex.Lock()
+	defer s.loopMutex.Unlock()
+	return s.loopCount
+}
+
+// applyLoopLimit turns looping off if the current pass is the last one, and on otherwise. loopMutex must be held.
+func (s *SunvoxChannel) applyLoopLimit() error {
+	if s.loopLimit <= 0 {
+		return nil
+	}
+	lastPass := s.loopCount >= s.loopLimit-1
+	if lastPass == s.IsLooping() {
+		// Autostop is changed without pausing the audio engine so the current pass isn't interrupted
+		return s.doSetLooping(!lastPass)
+	}
+	return nil
+}
+
+// clearLoopLimit removes the loop limit, restoring the looping setting. loopMutex must be held.
+func (s *SunvoxChannel) clearLoopLimit() error {
+	if s.loopLimit <= 0 {
+		return nil
+	}
+	s.loopLimit = 0
+	s.loopThen = nil
+	if s.loopRestoreLooping != s.IsLooping() {
+		return s.doSetLooping(s.loopRestoreLooping)
+	}
+	return nil
+}
+
+// updateLoops updates the loop count from the events detected in a poll, returning the function to call if the last
+// pass has just finished.
+func (s *SunvoxChannel) updateLoops(events []Event, restarted bool) func() {
+
+	s.loopMutex.Lock()
+	defer s.loopMutex.Unlock()
+
+	if restarted {
+		s.loopCount = 0
+		s.applyLoopLimit()
+	}
+
+	for _, event := range events {
+
+		switch event.Type {
+
+		case EventLooped:
+			s.loopCount++
+			s.applyLoopLimit()
+
+		case EventSongEnded:
+			if s.loopLimit > 0 && s.loopCount >= s.loopLimit-1 {
+				then := s.loopThen
+				s.clearLoopLimit()
+				return then
+			}
+
+		}
+
+	}
+
+	return nil
+
+}
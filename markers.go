@@ -0,0 +1,175 @@
+package sunvoxgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrorMarkerNotFound = errors.New("error: no marker exists with the given name")
+
+// markerLoopRegionName is the name of the LoopRegion used for LoopBetweenMarkers().
+const markerLoopRegionName = "sunvoxgo.markerLoop"
+
+// Marker represents a named position in a SunvoxChannel's project, taken from the name of a pattern that starts there.
+type Marker struct {
+	Name    string
+	Line    int            // The line the marker is on (i.e. the X position of its pattern)
+	Pattern *SunvoxPattern // The pattern the marker comes from
+}
+
+// SetMarkerPrefix sets the prefix that pattern names must start with to be used as markers (for example, with a prefix
+// of "#", a pattern named "#chorus" creates a marker named "chorus", while a pattern named "bass" creates no marker).
+// If the prefix is empty (the default), every pattern with a name creates a marker of the same name.
+func (s *SunvoxChannel) SetMarkerPrefix(prefix string) {
+	s.markerMutex.Lock()
+	defer s.markerMutex.Unlock()
+	if prefix != s.markerPrefix {
+		s.markerPrefix = prefix
+		s.markers = nil
+	}
+}
+
+// MarkerPrefix returns the prefix that pattern names must start with to be used as markers.
+func (s *SunvoxChannel) MarkerPrefix() string {
+	s.markerMutex.Lock()
+	defer s.markerMutex.Unlock()
+	return s.markerPrefix
+}
+
+// Markers returns the SunvoxChannel's markers, ordered by line. A name can be used for multiple markers if several
+// patterns at different positions share it (for example, clones of a pattern).
+//
+// The markers are built from the project's patterns the first time they're needed and cached afterwards; moving a
+// pattern through this package or loading a file clears the cache, but RefreshMarkers() should be called after changing
+// patterns otherwise.
+func (s *SunvoxChannel) Markers() []Marker {
+	return slices.Clone(s.markerTable())
+}
+
+// Marker returns the first marker (i.e. the one with the lowest line) with the given name, and whether it exists.
+func (s *SunvoxChannel) Marker(name string) (Marker, bool) {
+	for _, marker := range s.markerTable() {
+		if marker.Name == name {
+			return marker, true
+		}
+	}
+	return Marker{}, false
+}
+
+// RefreshMarkers rebuilds the SunvoxChannel's markers from its patterns.
+func (s *SunvoxChannel) RefreshMarkers() {
+	s.invalidateMarkers()
+	s.markerTable()
+}
+
+// SeekMarker seeks playback to the first marker with the given name. If no marker exists with the given name,
+// ErrorMarkerNotFound is returned. See Seek() for more information.
+func (s *SunvoxChannel) SeekMarker(name string) error {
+	marker, ok := s.Marker(name)
+	if !ok {
+		return ErrorMarkerNotFound
+	}
+	return s.Seek(marker.Line)
+}
+
+// LoopBetweenMarkers loops playback from the first marker named start to the first marker named end (which isn't
+// played itself), confining playback to that stretch. If end is empty, the loop ends where start's pattern ends.
+// This is done with a LoopRegion named "sunvoxgo.markerLoop"; see SetActiveLoopRegion() for more information, and
+// ClearLoopRegion() to stop looping. If either marker doesn't exist, ErrorMarkerNotFound is returned.
+func (s *SunvoxChannel) LoopBetweenMarkers(start, end string) error {
+
+	startMarker, ok := s.Marker(start)
+	if !ok {
+		return ErrorMarkerNotFound
+	}
+
+	endLine := startMarker.Pattern.X2()
+	endName := fmt.Sprintf("the end of marker %s's pattern", start)
+
+	if end != "" {
+		endMarker, ok := s.Marker(end)
+		if !ok {
+			return ErrorMarkerNotFound
+		}
+		endLine = endMarker.Line
+		endName = "marker " + end
+	}
+
+	if endLine <= startMarker.Line {
+		return errors.New(fmt.Sprintf("error looping from marker %s to %s; %s must come after marker %s", start, endName, endName, start))
+	}
+
+	if err := s.SetLoopRegion(LoopRegion{Name: markerLoopRegionName, Start: startMarker.Line, End: endLine, Confine: true}); err != nil {
+		return err
+	}
+
+	return s.SetActiveLoopRegion(markerLoopRegionName)
+
+}
+
+// OnMarker adds a callback to be called when the playhead reaches a marker while playing. Seeking directly to a marker
+// (as with SeekMarker()) counts as reaching it.
+// This is a shortcut for Subscribe() with EventMarker; see Subscribe() for more information.
+func (s *SunvoxChannel) OnMarker(ctx context.Context, onMarker func(marker Marker)) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onMarker(*event.Marker)
+		return true
+	}, EventMarker)
+}
+
+// markerTable returns the SunvoxChannel's cached markers, building them if necessary. The returned slice must not be modified.
+func (s *SunvoxChannel) markerTable() []Marker {
+
+	s.markerMutex.Lock()
+	defer s.markerMutex.Unlock()
+
+	if s.markers != nil {
+		return s.markers
+	}
+
+	markers := []Marker{}
+
+	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+
+		name, ok := strings.CutPrefix(pattern.Name(), s.markerPrefix)
+		if !ok || name == "" {
+			return true
+		}
+
+		marker := Marker{Name: name, Line: pattern.X(), Pattern: pattern}
+
+		// Patterns stacked at the same position under the same name only make one marker
+		if !slices.ContainsFunc(markers, func(m Marker) bool { return m.Name == marker.Name && m.Line == marker.Line }) {
+			markers = append(markers, marker)
+		}
+
+		return true
+
+	})
+
+	slices.SortStableFunc(markers, func(a, b Marker) int { return a.Line - b.Line })
+
+	s.markers = markers
+
+	return markers
+
+}
+
+// invalidateMarkers clears the SunvoxChannel's cached markers so they're rebuilt the next time they're needed.
+func (s *SunvoxChannel) invalidateMarkers() {
+	s.markerMutex.Lock()
+	s.markers = nil
+	s.markerMutex.Unlock()
+}
+
+// markersBetween calls fn for each marker in the given range of lines (from exclusively after "from" through "to", inclusively).
+func (s *SunvoxChannel) markersBetween(from, to int, fn func(marker Marker)) {
+	for _, marker := range s.markerTable() {
+		if marker.Line > from && marker.Line <= to {
+			fn(marker)
+		}
+	}
+}
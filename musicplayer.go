@@ -0,0 +1,198 @@
+package sunvoxgo
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// MusicPlayer plays songs one after another, crossfading between them. It does this by double-buffering two
+// SunvoxChannels: the next song is loaded into whichever channel is idle, and then crossfaded in while the current
+// one is crossfaded out.
+type MusicPlayer struct {
+	FadeDuration time.Duration // How long crossfades take; if 0, songs are switched instantly
+	Curve        Curve         // The curve used for crossfades; if nil, CurveEqualPower is used
+
+	// Quantize indicates when the crossfade to the next song begins, relative to the song that's currently playing (so
+	// QuantizeBars(1) starts the crossfade at the start of the next bar); see SunvoxChannel.ScheduleTransition().
+	// By default, it's QuantizeNow, so the crossfade begins immediately.
+	Quantize Quantize
+
+	mutex      sync.Mutex
+	channels   [2]*SunvoxChannel
+	current    *SunvoxChannel
+	crossfade  *Crossfade
+	transition *Future
+}
+
+// NewMusicPlayer creates a new MusicPlayer, creating two SunvoxChannels for it with the given ID.
+// fadeDuration is how long crossfades between songs take.
+func NewMusicPlayer(id any, fadeDuration time.Duration) (*MusicPlayer, error) {
+
+	m := &MusicPlayer{
+		FadeDuration: fadeDuration,
+	}
+
+	for i := range m.channels {
+		channel, err := engine.CreateChannel(id)
+		if err != nil {
+			if i > 0 {
+				m.channels[0].Close()
+			}
+			return nil, err
+		}
+		m.channels[i] = channel
+	}
+
+	return m, nil
+
+}
+
+// PlayFromPath loads the project at the given filepath and crossfades to it. See PlayFromBytes() for more information.
+func (m *MusicPlayer) PlayFromPath(filepath string) error {
+	return m.play(func(channel *SunvoxChannel) error { return channel.LoadFileFromPath(filepath) })
+}
+
+// PlayFromFS loads the project at the given filepath in the file system and crossfades to it. See PlayFromBytes() for
+// more information.
+func (m *MusicPlayer) PlayFromFS(fileSystem fs.FS, filepath string) error {
+	return m.play(func(channel *SunvoxChannel) error { return channel.LoadFileFromFS(fileSystem, filepath) })
+}
+
+// PlayFromBytes loads the project from the given .sunvox file data into the MusicPlayer's idle channel and crossfades to
+// it, according to the MusicPlayer's FadeDuration, Curve and Quantize settings. The loaded channel becomes the MusicPlayer's
+// current channel immediately, even if the crossfade itself waits for a boundary in the previous song.
+//
+// If another crossfade is still in progress, it's stopped; if the idle channel is still fading out from it, that channel
+// stops abruptly so it can be reused.
+func (m *MusicPlayer) PlayFromBytes(data []byte) error {
+	return m.play(func(channel *SunvoxChannel) error { return channel.LoadFileFromBytes(data) })
+}
+
+func (m *MusicPlayer) play(load func(channel *SunvoxChannel) error) error {
+
+	m.mutex.Lock()
+
+	if m.transition != nil {
+		m.transition.Cancel()
+		m.transition = nil
+	}
+
+	if m.crossfade != nil {
+		m.crossfade.Stop()
+		m.crossfade = nil
+	}
+
+	outgoing := m.current
+	incoming := m.channels[0]
+	if outgoing == incoming {
+		incoming = m.channels[1]
+	}
+
+	if err := incoming.Reopen(); err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+
+	if err := load(incoming); err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+
+	m.current = incoming
+
+	duration := m.FadeDuration
+	curve := m.Curve
+	quantize := m.Quantize
+
+	m.mutex.Unlock()
+
+	start := func() error {
+		crossfade := engine.Crossfade(outgoing, incoming, duration, curve)
+		m.mutex.Lock()
+		m.crossfade = crossfade
+		m.mutex.Unlock()
+		return nil
+	}
+
+	if outgoing == nil || !outgoing.IsPlaying() {
+		return start()
+	}
+
+	transition := outgoing.ScheduleTransition(quantize, start)
+
+	m.mutex.Lock()
+	m.transition = transition
+	m.mutex.Unlock()
+
+	return nil
+
+}
+
+// Stop fades out the MusicPlayer's songs over the given duration, stopping them afterwards. A pending switch to another
+// song is canceled.
+func (m *MusicPlayer) Stop(fadeDuration time.Duration) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.transition != nil {
+		m.transition.Cancel()
+		m.transition = nil
+	}
+
+	if m.crossfade != nil {
+		m.crossfade.Stop()
+	}
+
+	// The previous song may still be playing if it was crossfading out or waiting to be switched from, so both channels
+	// are faded out together
+	playing := []*SunvoxChannel{}
+	for _, channel := range m.channels {
+		if channel.IsPlaying() {
+			playing = append(playing, channel)
+		}
+	}
+
+	m.crossfade = engine.crossfade(playing, nil, fadeDuration, m.Curve)
+
+}
+
+// Current returns the SunvoxChannel playing (or about to play) the current song, or nil if no song has been played yet.
+func (m *MusicPlayer) Current() *SunvoxChannel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.current
+}
+
+// Crossfade returns the crossfade in progress (or the last one to have run), or nil if there hasn't been one.
+func (m *MusicPlayer) Crossfade() *Crossfade {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.crossfade
+}
+
+// Close stops playback and closes both of the MusicPlayer's channels.
+func (m *MusicPlayer) Close() error {
+
+	m.mutex.Lock()
+	if m.transition != nil {
+		m.transition.Cancel()
+		m.transition = nil
+	}
+	if m.crossfade != nil {
+		m.crossfade.Stop()
+	}
+	m.current = nil
+	m.mutex.Unlock()
+
+	var err error
+	for _, channel := range m.channels {
+		if closeErr := channel.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+
+}
@@ -0,0 +1,392 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ErrorNoteOutOfRange = errors.New("error: the note is outside of the range of playable notes (C0 to F#10)")
+
+// Note represents a note value as Sunvox uses it in patterns and events: 0 means no note, 1 to 127 are playable notes
+// (from C0 to F#10, so C5 is 61 and A4 is 58), and values from 128 up are the NoteCommand constants (like NoteCommandNoteOff).
+// As it's a uint8, it converts directly to and from the values of SunvoxPatternData (Note(noteValue)), and to the values
+// taken by SunvoxChannel.SendEvent() (int(note)).
+type Note uint8
+
+const (
+	NoteNone Note = 0   // No note
+	NoteMin  Note = 1   // The lowest playable note (C0)
+	NoteMax  Note = 127 // The highest playable note (F#10)
+	NoteA4   Note = 58  // A4, which plays at 440 Hz
+	NoteC5   Note = 61  // C5, the default note Sunvox places in patterns
+)
+
+var noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+var noteFlatNames = [12]string{"C", "Db", "D", "Eb", "E", "F", "Gb", "G", "Ab", "A", "Bb", "B"}
+
+var notePitchClasses = map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+
+var noteCommandNames = map[Note]string{
+	NoteCommandNoteOff:     "NoteOff",
+	NoteCommandAllNotesOff: "AllNotesOff",
+	NoteCommandCleanSynths: "CleanSynths",
+	NoteCommandStop:        "Stop",
+	NoteCommandPlay:        "Play",
+	NoteCommandSetPitch:    "SetPitch",
+	NoteCommandCleanModule: "CleanModule",
+}
+
+// NewNote returns the note with the given pitch class (from 0 for C to 11 for B) in the given octave (so NewNote(0, 5)
+// is C5). If the note is outside of the range of playable notes, ErrorNoteOutOfRange is returned.
+func NewNote(pitchClass, octave int) (Note, error) {
+	value := octave*12 + pitchClass + 1
+	if pitchClass < 0 || pitchClass > 11 || value < int(NoteMin) || value > int(NoteMax) {
+		return NoteNone, ErrorNoteOutOfRange
+	}
+	return Note(value), nil
+}
+
+// ParseNote parses a note name, like "C5", "C#5", "Db5" or "bb3" (B flat 3). The letter can be in either case, and can
+// be followed by any number of sharps ("#" or "♯") or flats ("b" or "♭"), and then the octave (from 0 to 10).
+// Accidentals can cross octaves, so "B#4" is the same note as "C5".
+func ParseNote(name string) (Note, error) {
+
+	text := strings.TrimSpace(name)
+
+	if text == "" {
+		return NoteNone, errors.New("error: can't parse an empty note name")
+	}
+
+	pitchClass, ok := notePitchClasses[text[0]|0x20] // Lowercased
+	if !ok {
+		return NoteNone, errors.New(fmt.Sprintf("error: can't parse note name \"%s\"; it must start with a letter from A to G", name))
+	}
+
+	text = text[1:]
+
+	for {
+		if strings.HasPrefix(text, "#") {
+			pitchClass++
+			text = text[1:]
+		} else if strings.HasPrefix(text, "♯") {
+			pitchClass++
+			text = text[len("♯"):]
+		} else if strings.HasPrefix(text, "b") {
+			pitchClass--
+			text = text[1:]
+		} else if strings.HasPrefix(text, "♭") {
+			pitchClass--
+			text = text[len("♭"):]
+		} else {
+			break
+		}
+	}
+
+	octave := 0
+	if text == "" {
+		return NoteNone, errors.New(fmt.Sprintf("error: can't parse note name \"%s\"; it's missing its octave", name))
+	}
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return NoteNone, errors.New(fmt.Sprintf("error: can't parse note name \"%s\"; \"%s\" isn't a valid octave", name, text))
+		}
+		octave = octave*10 + int(r-'0')
+		if octave > 10 {
+			return NoteNone, errors.New(fmt.Sprintf("error: can't parse note name \"%s\"; octaves range from 0 to 10", name))
+		}
+	}
+
+	value := octave*12 + pitchClass + 1
+	if value < int(NoteMin) || value > int(NoteMax) {
+		return NoteNone, ErrorNoteOutOfRange
+	}
+
+	return Note(value), nil
+
+}
+
+// String returns the Note's name with its octave, like "C#5". Note commands return their names (like "NoteOff"), and
+// NoteNone returns "None".
+func (n Note) String() string {
+	switch {
+	case n == NoteNone:
+		return "None"
+	case n.IsCommand():
+		if name, ok := noteCommandNames[n]; ok {
+			return name
+		}
+		return fmt.Sprintf("Note(%d)", uint8(n))
+	}
+	return fmt.Sprintf("%s%d", n.Name(), n.Octave())
+}
+
+// Name returns the Note's name without its octave, using sharps (like "C#"). If the Note isn't a playable note, an
+// empty string is returned.
+func (n Note) Name() string {
+	if !n.IsValid() {
+		return ""
+	}
+	return noteNames[n.PitchClass()]
+}
+
+// FlatName returns the Note's name without its octave, using flats (like "Db"). If the Note isn't a playable note, an
+// empty string is returned.
+func (n Note) FlatName() string {
+	if !n.IsValid() {
+		return ""
+	}
+	return noteFlatNames[n.PitchClass()]
+}
+
+// PitchClass returns the Note's position within its octave, from 0 (C) to 11 (B).
+func (n Note) PitchClass() int {
+	return (int(n) - 1) % 12
+}
+
+// Octave returns the Note's octave (so C5 and B5 are both in octave 5).
+func (n Note) Octave() int {
+	return (int(n) - 1) / 12
+}
+
+// IsValid returns if the Note is a playable note (from 1 to 127); that is, it isn't NoteNone or a note command.
+func (n Note) IsValid() bool {
+	return n >= NoteMin && n <= NoteMax
+}
+
+// IsCommand returns if the Note is one of the NoteCommand constants (or any other value from 128 up).
+func (n Note) IsCommand() bool {
+	return n >= NoteCommandNoteOff
+}
+
+// Transpose returns the Note transposed by the given interval, and whether the transposed note is playable. If it
+// wouldn't be, the Note is returned unchanged along with false, so transposing can never turn a note into a note command
+// (or into NoteNone). If the Note isn't a playable note to begin with (NoteNone or a note command), it's returned unchanged
+// along with true, so a pattern's notes can be transposed without having to skip its note commands.
+func (n Note) Transpose(interval Interval) (Note, bool) {
+	if !n.IsValid() {
+		return n, true
+	}
+	value := int(n) + int(interval)
+	if value < int(NoteMin) || value > int(NoteMax) {
+		return n, false
+	}
+	return Note(value), true
+}
+
+// TransposeClamped returns the Note transposed by the given interval, clamped to the range of playable notes. As with
+// Transpose(), NoteNone and note commands are returned unchanged.
+func (n Note) TransposeClamped(interval Interval) Note {
+	if !n.IsValid() {
+		return n
+	}
+	return Note(min(max(int(n)+int(interval), int(NoteMin)), int(NoteMax)))
+}
+
+// TransposeFolded returns the Note transposed by the given interval; if the result would be outside of the range of
+// playable notes, it's moved by octaves until it's within it, so that it keeps its pitch class. As with Transpose(),
+// NoteNone and note commands are returned unchanged.
+func (n Note) TransposeFolded(interval Interval) Note {
+	if !n.IsValid() {
+		return n
+	}
+	value := int(n) + int(interval)
+	for value < int(NoteMin) {
+		value += 12
+	}
+	for value > int(NoteMax) {
+		value -= 12
+	}
+	return Note(value)
+}
+
+// IntervalTo returns the interval from the Note to the given note (so it's negative if the other note is lower).
+func (n Note) IntervalTo(other Note) Interval {
+	return Interval(int(other) - int(n))
+}
+
+// Frequency returns the Note's frequency in Hz in twelve-tone equal temperament, with A4 at 440 Hz. If the Note isn't
+// a playable note, 0 is returned.
+func (n Note) Frequency() float64 {
+	if !n.IsValid() {
+		return 0
+	}
+	return 440 * math.Pow(2, float64(int(n)-int(NoteA4))/12)
+}
+
+// NoteFromFrequency returns the playable note closest to the given frequency in Hz (with A4 at 440 Hz), along with how
+// far the frequency is from it in cents (hundredths of a semitone, from -50 to 50). Frequencies outside of the range of
+// playable notes are clamped to it, in which case the offset can be larger. If hz is less than or equal to 0, NoteNone
+// is returned.
+func NoteFromFrequency(hz float64) (Note, float64) {
+	if hz <= 0 {
+		return NoteNone, 0
+	}
+	semitones := float64(NoteA4) + 12*math.Log2(hz/440)
+	note := Note(min(max(math.Round(semitones), float64(NoteMin)), float64(NoteMax)))
+	return note, (semitones - float64(note)) * 100
+}
+
+// Interval represents a distance between two notes, in semitones.
+type Interval int
+
+const (
+	IntervalUnison Interval = iota
+	IntervalMinorSecond
+	IntervalMajorSecond
+	IntervalMinorThird
+	IntervalMajorThird
+	IntervalPerfectFourth
+	IntervalTritone
+	IntervalPerfectFifth
+	IntervalMinorSixth
+	IntervalMajorSixth
+	IntervalMinorSeventh
+	IntervalMajorSeventh
+	IntervalOctave
+)
+
+var intervalNames = [13]string{
+	"unison", "minor second", "major second", "minor third", "major third", "perfect fourth", "tritone",
+	"perfect fifth", "minor sixth", "major sixth", "minor seventh", "major seventh", "octave",
+}
+
+// String returns the Interval's name, like "minor third". Intervals larger than an octave are given as a simple interval
+// plus octaves, and descending intervals are prefixed with "descending".
+func (i Interval) String() string {
+	if i < 0 {
+		return "descending " + (-i).String()
+	}
+	if i <= IntervalOctave {
+		return intervalNames[i]
+	}
+	octaves := i.Octaves()
+	if octaves == 1 {
+		return fmt.Sprintf("%s + 1 octave", intervalNames[i.Simple()])
+	}
+	return fmt.Sprintf("%s + %d octaves", intervalNames[i.Simple()], octaves)
+}
+
+// Octaves returns how many whole octaves the Interval spans (ignoring its direction).
+func (i Interval) Octaves() int {
+	return abs(int(i)) / 12
+}
+
+// Simple returns the Interval reduced to within an octave (from 0 to 11), keeping its pitch class; so a major tenth is a
+// major third, and a descending minor third is a major sixth.
+func (i Interval) Simple() Interval {
+	return Interval(int(i) - floorDiv(int(i), 12)*12)
+}
+
+// Invert returns the inversion of the Interval within an octave (so a major third becomes a minor sixth).
+func (i Interval) Invert() Interval {
+	return (IntervalOctave - i.Simple()).Simple()
+}
+
+// Scale represents a musical scale as the intervals of its notes from its root, within an octave and in ascending order.
+type Scale []Interval
+
+var (
+	ScaleMajor           = Scale{0, 2, 4, 5, 7, 9, 11}
+	ScaleMinor           = Scale{0, 2, 3, 5, 7, 8, 10} // The natural minor scale
+	ScaleHarmonicMinor   = Scale{0, 2, 3, 5, 7, 8, 11}
+	ScaleMelodicMinor    = Scale{0, 2, 3, 5, 7, 9, 11} // The ascending melodic minor scale
+	ScaleDorian          = Scale{0, 2, 3, 5, 7, 9, 10}
+	ScalePhrygian        = Scale{0, 1, 3, 5, 7, 8, 10}
+	ScaleLydian          = Scale{0, 2, 4, 6, 7, 9, 11}
+	ScaleMixolydian      = Scale{0, 2, 4, 5, 7, 9, 10}
+	ScaleLocrian         = Scale{0, 1, 3, 5, 6, 8, 10}
+	ScaleMajorPentatonic = Scale{0, 2, 4, 7, 9}
+	ScaleMinorPentatonic = Scale{0, 3, 5, 7, 10}
+	ScaleBlues           = Scale{0, 3, 5, 6, 7, 10}
+	ScaleWholeTone       = Scale{0, 2, 4, 6, 8, 10}
+	ScaleChromatic       = Scale{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+)
+
+// Degree returns the note of the given degree of the Scale from the given root, counting from 1 (so degree 1 is the root).
+// Degrees past the end of the scale continue into the next octave (so in a seven-note scale, degree 9 is degree 2 an
+// octave higher), and degrees below 1 continue into the octave below. If the note isn't playable, false is returned.
+func (s Scale) Degree(root Note, degree int) (Note, bool) {
+	if len(s) == 0 {
+		return NoteNone, false
+	}
+	return root.Transpose(s.degreeInterval(degree))
+}
+
+// Notes returns the notes of the Scale from the given root, spanning the given number of octaves (with 0 being treated as
+// 1), followed by the root of the octave above. Notes outside of the range of playable notes are left out.
+func (s Scale) Notes(root Note, octaves int) []Note {
+	notes := []Note{}
+	if len(s) == 0 || !root.IsValid() {
+		return notes
+	}
+	for degree := 1; degree <= len(s)*max(octaves, 1)+1; degree++ {
+		if note, ok := s.Degree(root, degree); ok {
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
+
+// Contains returns if the given note is in the Scale from the given root, in any octave.
+func (s Scale) Contains(root, note Note) bool {
+	if !root.IsValid() || !note.IsValid() {
+		return false
+	}
+	pitchClass := root.IntervalTo(note).Simple()
+	for _, interval := range s {
+		if interval.Simple() == pitchClass {
+			return true
+		}
+	}
+	return false
+}
+
+// Quantize returns the note in the Scale from the given root that's closest to the given note; if two are equally
+// close, the lower one is returned. NoteNone and note commands are returned unchanged.
+func (s Scale) Quantize(root, note Note) Note {
+
+	if len(s) == 0 || !root.IsValid() || !note.IsValid() {
+		return note
+	}
+
+	for distance := range 12 {
+		for _, direction := range []int{-1, 1} {
+			if candidate, ok := note.Transpose(Interval(distance * direction)); ok && s.Contains(root, candidate) {
+				return candidate
+			}
+		}
+	}
+
+	return note
+
+}
+
+// Chord returns the chord built on the given degree of the Scale from the given root by stacking thirds within the scale,
+// with the given number of notes (so 3 gives a triad, and 4 a seventh chord). For example, degree 2 of ScaleMajor gives
+// a minor chord. If notes is less than or equal to 0, the chord is empty.
+func (s Scale) Chord(root Note, degree, notes int) Chord {
+	notes = max(notes, 0)
+	degrees := make([]int, 0, notes)
+	for i := range notes {
+		degrees = append(degrees, degree+i*2)
+	}
+	return NewChordFromDegrees(root, s, degrees...)
+}
+
+// degreeInterval returns the interval from the Scale's root to the given degree, counting from 1. The Scale mustn't be
+// empty.
+func (s Scale) degreeInterval(degree int) Interval {
+	index := degree - 1
+	octave := floorDiv(index, len(s))
+	return s[index-octave*len(s)] + Interval(octave*12)
+}
+
+// abs returns the absolute value of the given integer.
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
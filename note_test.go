@@ -0,0 +1,189 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseNote(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		want    Note
+		wantErr bool
+	}{
+		{name: "C5", want: 61},
+		{name: "c5", want: 61},
+		{name: " A4 ", want: NoteA4},
+		{name: "C#5", want: 62},
+		{name: "Db5", want: 62},
+		{name: "C♯5", want: 62},
+		{name: "E♭5", want: 64},
+		{name: "bb3", want: 47},
+		{name: "C##5", want: 63},
+		{name: "B#4", want: 61},
+		{name: "Cb5", want: 60},
+		{name: "C0", want: NoteMin},
+		{name: "F#10", want: NoteMax},
+		{name: "G10", wantErr: true},
+		{name: "Cb0", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "   ", wantErr: true},
+		{name: "H5", wantErr: true},
+		{name: "C", wantErr: true},
+		{name: "C11", wantErr: true},
+		{name: "C5x", wantErr: true},
+		{name: "C-1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseNote(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want an error: %v", err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("got %v (%d), want %v (%d)", got, got, test.want, test.want)
+			}
+		})
+	}
+
+	if _, err := ParseNote("G10"); !errors.Is(err, ErrorNoteOutOfRange) {
+		t.Errorf("got error %v parsing a note past the highest one, want ErrorNoteOutOfRange", err)
+	}
+
+}
+
+func TestNoteNames(t *testing.T) {
+
+	tests := []struct {
+		note     Note
+		want     string
+		wantFlat string
+	}{
+		{note: NoteC5, want: "C5", wantFlat: "C"},
+		{note: 62, want: "C#5", wantFlat: "Db"},
+		{note: NoteA4, want: "A4", wantFlat: "A"},
+		{note: NoteMin, want: "C0", wantFlat: "C"},
+		{note: NoteMax, want: "F#10", wantFlat: "Gb"},
+		{note: NoteNone, want: "None"},
+		{note: NoteCommandNoteOff, want: "NoteOff"},
+		{note: 200, want: "Note(200)"},
+	}
+
+	for _, test := range tests {
+		if got := test.note.String(); got != test.want {
+			t.Errorf("Note(%d).String() = %q, want %q", test.note, got, test.want)
+		}
+		if got := test.note.FlatName(); got != test.wantFlat {
+			t.Errorf("Note(%d).FlatName() = %q, want %q", test.note, got, test.wantFlat)
+		}
+		if test.note.IsValid() {
+			if parsed, err := ParseNote(test.want); err != nil || parsed != test.note {
+				t.Errorf("ParseNote(%q) = %v, %v; want %v", test.want, parsed, err, test.note)
+			}
+		}
+	}
+
+}
+
+func TestTranspose(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		note        Note
+		interval    Interval
+		want        Note
+		wantOk      bool
+		wantClamped Note
+		wantFolded  Note
+	}{
+		{name: "up an octave", note: NoteC5, interval: IntervalOctave, want: 73, wantOk: true, wantClamped: 73, wantFolded: 73},
+		{name: "down a fifth", note: NoteC5, interval: -IntervalPerfectFifth, want: 54, wantOk: true, wantClamped: 54, wantFolded: 54},
+		{name: "unison", note: NoteC5, interval: IntervalUnison, want: 61, wantOk: true, wantClamped: 61, wantFolded: 61},
+		{name: "to the highest note", note: 120, interval: 7, want: NoteMax, wantOk: true, wantClamped: NoteMax, wantFolded: NoteMax},
+		{name: "past the highest note", note: 125, interval: 5, want: 125, wantOk: false, wantClamped: NoteMax, wantFolded: 118},
+		{name: "past the lowest note", note: 5, interval: -12, want: 5, wantOk: false, wantClamped: NoteMin, wantFolded: 5},
+		{name: "far past the lowest note", note: 2, interval: -30, want: 2, wantOk: false, wantClamped: NoteMin, wantFolded: 8},
+		{name: "no note", note: NoteNone, interval: 5, want: NoteNone, wantOk: true, wantClamped: NoteNone, wantFolded: NoteNone},
+		{name: "note command", note: NoteCommandNoteOff, interval: -3, want: NoteCommandNoteOff, wantOk: true, wantClamped: NoteCommandNoteOff, wantFolded: NoteCommandNoteOff},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, ok := test.note.Transpose(test.interval); got != test.want || ok != test.wantOk {
+				t.Errorf("Transpose() = %v, %v; want %v, %v", got, ok, test.want, test.wantOk)
+			}
+			if got := test.note.TransposeClamped(test.interval); got != test.wantClamped {
+				t.Errorf("TransposeClamped() = %v, want %v", got, test.wantClamped)
+			}
+			if got := test.note.TransposeFolded(test.interval); got != test.wantFolded {
+				t.Errorf("TransposeFolded() = %v, want %v", got, test.wantFolded)
+			}
+		})
+	}
+
+}
+
+func TestIntervals(t *testing.T) {
+
+	tests := []struct {
+		interval   Interval
+		want       string
+		wantSimple Interval
+		wantInvert Interval
+	}{
+		{interval: IntervalUnison, want: "unison", wantSimple: 0, wantInvert: 0},
+		{interval: IntervalMinorThird, want: "minor third", wantSimple: 3, wantInvert: 9},
+		{interval: IntervalOctave, want: "octave", wantSimple: 0, wantInvert: 0},
+		{interval: 16, want: "major third + 1 octave", wantSimple: 4, wantInvert: 8},
+		{interval: 31, want: "perfect fifth + 2 octaves", wantSimple: 7, wantInvert: 5},
+		{interval: -3, want: "descending minor third", wantSimple: 9, wantInvert: 3},
+		{interval: -12, want: "descending octave", wantSimple: 0, wantInvert: 0},
+	}
+
+	for _, test := range tests {
+		if got := test.interval.String(); got != test.want {
+			t.Errorf("Interval(%d).String() = %q, want %q", test.interval, got, test.want)
+		}
+		if got := test.interval.Simple(); got != test.wantSimple {
+			t.Errorf("Interval(%d).Simple() = %d, want %d", test.interval, got, test.wantSimple)
+		}
+		if got := test.interval.Invert(); got != test.wantInvert {
+			t.Errorf("Interval(%d).Invert() = %d, want %d", test.interval, got, test.wantInvert)
+		}
+	}
+
+	if got := NoteC5.IntervalTo(NoteA4); got != -3 {
+		t.Errorf("got interval %d from C5 to A4, want -3", got)
+	}
+
+}
+
+func TestNoteFromFrequency(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		hz        float64
+		want      Note
+		wantCents float64
+	}{
+		{name: "A4", hz: 440, want: NoteA4, wantCents: 0},
+		{name: "A5", hz: 880, want: NoteA4 + 12, wantCents: 0},
+		{name: "sharp of A4", hz: 440 * math.Pow(2, 0.25/12), want: NoteA4, wantCents: 25},
+		{name: "flat of A#4", hz: 440 * math.Pow(2, 0.75/12), want: NoteA4 + 1, wantCents: -25},
+		{name: "round trip", hz: Note(30).Frequency(), want: 30, wantCents: 0},
+		{name: "no frequency", hz: 0, want: NoteNone, wantCents: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, cents := NoteFromFrequency(test.hz)
+			if got != test.want || math.Abs(cents-test.wantCents) > 1e-6 {
+				t.Errorf("got %v, %v cents; want %v, %v cents", got, cents, test.want, test.wantCents)
+			}
+		})
+	}
+
+}
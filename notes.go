@@ -0,0 +1,243 @@
+package sunvoxgo
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+)
+
+// NoteEvent represents a note in a pattern being played (see SunvoxChannel.OnNote()).
+type NoteEvent struct {
+	Pattern *SunvoxPattern // The pattern the note is in
+	Track   int            // The track (column) of the pattern the note is on
+	Line    int            // The line of the song the note is on (so the pattern's X position plus the line within the pattern)
+
+	Note            Note          // The note's value; this can also be one of the NoteCommand constants, like NoteCommandNoteOff
+	Velocity        uint8         // The note's velocity; 0 means the default velocity
+	Module          *SunvoxModule // The module the note plays; nil if the note has no module set
+	Controller      uint16        // The controller / effect column of the note
+	ControllerValue uint16        // The controller / effect value of the note
+
+	Time time.Time // When the note is expected to be heard
+}
+
+// SetNoteLookAhead sets how far ahead of the playhead notes are scanned for note events (see OnNote()), so that they can
+// be delivered before they're heard (for example, to spawn targets in a rhythm game that need time to travel to the
+// hit zone). By default, it's 0, so notes are delivered as Sunvox's audio engine plays them, which is slightly before
+// they're heard (see SunvoxEngine.Latency()).
+//
+// Notes past the end of the song or the active loop region aren't scanned ahead of time, so notes just after a loop point
+// are delivered once playback loops.
+func (s *SunvoxChannel) SetNoteLookAhead(lookAhead time.Duration) {
+	s.noteLookAhead.Store(int64(max(lookAhead, 0)))
+}
+
+// NoteLookAhead returns how far ahead of the playhead notes are scanned for note events. See SetNoteLookAhead() for more
+// information.
+func (s *SunvoxChannel) NoteLookAhead() time.Duration {
+	return time.Duration(s.noteLookAhead.Load())
+}
+
+// OnNote adds a callback to be called for each note in the SunvoxChannel's patterns as it's played, in order (first by
+// line, then by pattern and track). Notes in muted patterns are skipped. Seeking to a line (or playback looping to it)
+// plays the notes on that line, but the notes between the previous line and the new one are skipped.
+// The time each note is expected to be heard is given in NoteEvent.Time; see SetNoteLookAhead() to receive notes before then.
+// Each pattern's notes are cached after they're first read; see RefreshNotes() for when the cache needs to be cleared.
+//
+// This is a shortcut for Subscribe() with EventNote; see Subscribe() for more information.
+func (s *SunvoxChannel) OnNote(ctx context.Context, onNote func(note NoteEvent)) *Subscription {
+	return s.Subscribe(ctx, func(event Event) bool {
+		onNote(*event.Note)
+		return true
+	}, EventNote)
+}
+
+// noteScanLimit returns the line after the last one that can be scanned ahead of the playhead for notes; that's the end
+// of the active loop region (if there is one), or the end of the song.
+func (s *SunvoxChannel) noteScanLimit() int {
+	if region, ok := s.ActiveLoopRegion(); ok {
+		return region.End
+	}
+	return s.LengthInLines()
+}
+
+// noteScanTarget returns the last line that should be scanned for notes with the playhead on the given line.
+func (s *SunvoxChannel) noteScanTarget(line int) int {
+
+	target := line
+
+	if lookAhead := s.NoteLookAhead(); lookAhead > 0 {
+		lpm := float64(s.LPM())
+		if lpm > 0 && !math.IsInf(lpm, 0) {
+			target = int(math.Floor(s.CurrentPosition() + lookAhead.Minutes()*lpm))
+		}
+	}
+
+	return max(min(target, s.noteScanLimit()-1), line)
+
+}
+
+// notesBetween calls forEach for each note in the SunvoxChannel's unmuted patterns on the lines after from, up to and
+// including to, in order.
+func (s *SunvoxChannel) notesBetween(from, to int, now time.Time, forEach func(note NoteEvent)) {
+
+	if to <= from {
+		return
+	}
+
+	notes := []NoteEvent{}
+
+	// The time each line will be heard is estimated from the current position and tempo
+	position := s.CurrentPosition()
+	lpm := float64(s.LPM())
+	latency := engine.Latency()
+	lineTime := func(line int) time.Time {
+		if lpm <= 0 || math.IsInf(lpm, 0) {
+			return now
+		}
+		return now.Add(time.Duration((float64(line)-position)*60/lpm*float64(time.Second)) + latency)
+	}
+
+	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+
+		x := pattern.X()
+
+		lineCount, err := pattern.LineCount()
+		if err != nil || lineCount <= 0 || x+lineCount <= from+1 || x > to {
+			return true
+		}
+
+		patternNotes, err := s.notesOf(pattern)
+		if err != nil || patternNotes.muted {
+			return true
+		}
+
+		first, _ := slices.BinarySearchFunc(patternNotes.notes, from+1-x, func(note patternNote, line int) int { return note.line - line })
+
+		for _, note := range patternNotes.notes[first:] {
+
+			line := x + note.line
+
+			if line > to {
+				break
+			}
+
+			notes = append(notes, NoteEvent{
+				Pattern:         pattern,
+				Track:           note.track,
+				Line:            line,
+				Note:            Note(note.Note),
+				Velocity:        note.Velocity,
+				Module:          s.ModuleByIndex(int(note.Module) - 1),
+				Controller:      note.Controller,
+				ControllerValue: note.ControllerValue,
+				Time:            lineTime(line),
+			})
+
+		}
+
+		return true
+
+	})
+
+	// Patterns are iterated in order, so sorting by line keeps notes on the same line in pattern and track order
+	slices.SortStableFunc(notes, func(a, b NoteEvent) int { return a.Line - b.Line })
+
+	for _, note := range notes {
+		forEach(note)
+	}
+
+}
+
+// patternNote is a note in a pattern, at the given track and line within the pattern.
+type patternNote struct {
+	SunvoxPatternNoteData
+	track int
+	line  int
+}
+
+// patternNotes holds the notes of a pattern, ordered by line and then track, along with whether the pattern is muted.
+type patternNotes struct {
+	notes []patternNote
+	muted bool
+}
+
+// RefreshNotes clears the SunvoxChannel's cached pattern notes, which are used for note events (see OnNote()). Notes are
+// read from each pattern the first time they're needed and cached afterwards; editing or muting a pattern through this
+// package (or loading a file) clears the pattern's notes, but RefreshNotes() should be called after writing to the Data
+// slice returned from SunvoxPattern.Data() directly.
+func (s *SunvoxChannel) RefreshNotes() {
+	s.invalidateNotes(-1)
+}
+
+// notesOf returns the given pattern's cached notes, reading them from the pattern while the channel is locked if they
+// aren't cached.
+func (s *SunvoxChannel) notesOf(pattern *SunvoxPattern) (*patternNotes, error) {
+
+	s.noteMutex.Lock()
+	cached, version := s.patternNotes[pattern.Index], s.noteVersion
+	s.noteMutex.Unlock()
+
+	if cached != nil {
+		return cached, nil
+	}
+
+	// The pattern's size is read before locking, as reading its line count can pause the engine
+	lineCount, err := pattern.LineCount()
+	if err != nil {
+		return nil, err
+	}
+
+	trackCount, err := pattern.TrackCount()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Lock(); err != nil {
+		return nil, err
+	}
+
+	data, err := pattern.data(lineCount, trackCount)
+	if err != nil {
+		s.Unlock()
+		return nil, err
+	}
+
+	// A negative value leaves the mute state as is, returning it
+	read := &patternNotes{muted: setPatternMute(int32(s.Index), int32(pattern.Index), -1) == 1}
+
+	for i, cell := range data.Data {
+		if cell.Note != 0 {
+			read.notes = append(read.notes, patternNote{SunvoxPatternNoteData: cell, track: i % data.trackCount, line: i / data.trackCount})
+		}
+	}
+
+	s.Unlock()
+
+	// If the notes were cleared while they were being read, they may be out of date, so they're used once but not cached
+	s.noteMutex.Lock()
+	if s.noteVersion == version {
+		if s.patternNotes == nil {
+			s.patternNotes = map[int]*patternNotes{}
+		}
+		s.patternNotes[pattern.Index] = read
+	}
+	s.noteMutex.Unlock()
+
+	return read, nil
+
+}
+
+// invalidateNotes clears the cached notes of the pattern with the given index so that they're read again the next time
+// they're needed; if the index is -1, the notes of all patterns are cleared.
+func (s *SunvoxChannel) invalidateNotes(patternIndex int) {
+	s.noteMutex.Lock()
+	s.noteVersion++
+	if patternIndex < 0 {
+		clear(s.patternNotes)
+	} else {
+		delete(s.patternNotes, patternIndex)
+	}
+	s.noteMutex.Unlock()
+}
@@ -0,0 +1,273 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrorFunctionHasNotReturned = errors.New("function has not returned")
+var ErrorFutureCanceled = errors.New("queued function was canceled before it was executed")
+var ErrorChannelClosed = errors.New("channel was closed before the queued function was executed")
+
+const (
+	futurePending int32 = iota
+	futureRunning
+	futureDone
+)
+
+// Future represents the result of a function that has been queued to execute at some point in the future
+// (for example, through one of the SunvoxChannel.Queue* functions).
+type Future struct {
+	done  chan struct{}
+	err   error
+	state atomic.Int32
+}
+
+func newFuture() *Future {
+	return &Future{
+		done: make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed once the queued function has been executed (or canceled).
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// IsDone returns if the queued function has been executed (or canceled).
+func (f *Future) IsDone() bool {
+	return f.state.Load() == futureDone
+}
+
+// Wait blocks until the queued function has been executed (or canceled) and returns its error, if any.
+func (f *Future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// Error returns the error returned from the queued function.
+// If the function has not returned yet, Error returns ErrorFunctionHasNotReturned.
+func (f *Future) Error() error {
+	if !f.IsDone() {
+		return ErrorFunctionHasNotReturned
+	}
+	return f.err
+}
+
+// Cancel cancels the queued function if it has not begun executing yet, in which case the Future
+// resolves with ErrorFutureCanceled. Cancel returns if the function was successfully canceled.
+func (f *Future) Cancel() bool {
+	if !f.state.CompareAndSwap(futurePending, futureRunning) {
+		return false
+	}
+	f.resolve(ErrorFutureCanceled)
+	return true
+}
+
+// start marks the Future as running; if it returns false, the Future was canceled and its function should not run.
+func (f *Future) start() bool {
+	return f.state.CompareAndSwap(futurePending, futureRunning)
+}
+
+func (f *Future) resolve(err error) {
+	f.err = err
+	f.state.Store(futureDone)
+	close(f.done)
+}
+
+type queuedCommand struct {
+	run    func() error
+	future *Future
+}
+
+// commandQueue executes queued commands for a SunvoxChannel on a background goroutine.
+type commandQueue struct {
+	mutex  sync.Mutex
+	worker *queueWorker
+}
+
+// queueWorker holds the commands waiting for a running background worker goroutine. Its pending commands are guarded by
+// the commandQueue's mutex.
+type queueWorker struct {
+	pending  []*queuedCommand
+	wake     chan struct{} // Signaled (without blocking) whenever commands are queued
+	quit     chan struct{}
+	finished chan struct{}
+}
+
+// Queue queues a function to be executed on the SunvoxChannel's background worker goroutine and returns immediately
+// with a Future that resolves once the function has been executed. All functions queued before the worker gets to them are
+// executed together while the audio engine is paused only once, so queueing several changes in a row is cheap.
+// Queue never blocks, so it can also be called from within a queued function (in which case the new function is
+// executed after the current batch).
+//
+// The function is executed with the audio engine already paused, so it shouldn't pause or resume the audio engine itself.
+// If the function panics, the panic is recovered and the Future resolves with an error.
+func (s *SunvoxChannel) Queue(function func() error) *Future {
+
+	cmd := &queuedCommand{
+		run:    function,
+		future: newFuture(),
+	}
+
+	s.queue.mutex.Lock()
+
+	if s.queue.worker == nil {
+		s.queue.worker = &queueWorker{
+			wake:     make(chan struct{}, 1),
+			quit:     make(chan struct{}),
+			finished: make(chan struct{}),
+		}
+		go s.runQueue(s.queue.worker)
+	}
+
+	worker := s.queue.worker
+	worker.pending = append(worker.pending, cmd)
+
+	s.queue.mutex.Unlock()
+
+	select {
+	case worker.wake <- struct{}{}:
+	default:
+		// The worker has already been woken up, and will pick up this command along with the others
+	}
+
+	return cmd.future
+
+}
+
+// QueuePlay queues Play() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueuePlay() *Future {
+	return s.Queue(s.doPlay)
+}
+
+// QueuePlayFromBeginning queues PlayFromBeginning() to be executed on the SunvoxChannel's background worker goroutine.
+// See Queue() for more information.
+func (s *SunvoxChannel) QueuePlayFromBeginning() *Future {
+	return s.Queue(s.doPlayFromBeginning)
+}
+
+// QueueStop queues Stop() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueueStop() *Future {
+	return s.Queue(s.doStop)
+}
+
+// QueueSeek queues Seek() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueueSeek(lineNum int) *Future {
+	return s.Queue(func() error { return s.doSeek(lineNum) })
+}
+
+// QueueSetLooping queues SetLooping() to be executed on the SunvoxChannel's background worker goroutine.
+// See Queue() for more information.
+func (s *SunvoxChannel) QueueSetLooping(loop bool) *Future {
+	return s.Queue(func() error { return s.doSetLooping(loop) })
+}
+
+// QueueSetBPM queues SetBPM() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueueSetBPM(bpm float32) *Future {
+	return s.Queue(func() error { return s.SetBPM(bpm) })
+}
+
+// QueueSetTPL queues SetTPL() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueueSetTPL(tpl int) *Future {
+	return s.Queue(func() error { return s.SetTPL(tpl) })
+}
+
+func (s *SunvoxChannel) runQueue(worker *queueWorker) {
+
+	defer close(worker.finished)
+
+	started := []bool{}
+	errs := []error{}
+
+	for {
+
+		select {
+
+		case <-worker.quit:
+
+			s.queue.mutex.Lock()
+			batch := worker.pending
+			worker.pending = nil
+			s.queue.mutex.Unlock()
+
+			for _, cmd := range batch {
+				if cmd.future.start() {
+					cmd.future.resolve(ErrorChannelClosed)
+				}
+			}
+
+			return
+
+		case <-worker.wake:
+
+			s.queue.mutex.Lock()
+			batch := worker.pending
+			worker.pending = nil
+			s.queue.mutex.Unlock()
+
+			if len(batch) == 0 {
+				continue
+			}
+
+			started = started[:0]
+			errs = errs[:0]
+
+			// It's faster to make changes while the audio engine is paused, so we only pause once for the whole batch.
+			s.pauseEngine()
+
+			for _, cmd := range batch {
+				// Canceled commands have already been resolved, so they're skipped
+				if cmd.future.start() {
+					started = append(started, true)
+					errs = append(errs, runQueuedCommand(cmd))
+				} else {
+					started = append(started, false)
+					errs = append(errs, nil)
+				}
+			}
+
+			s.resumeEngine()
+
+			for i, cmd := range batch {
+				if started[i] {
+					cmd.future.resolve(errs[i])
+				}
+			}
+
+		}
+
+	}
+
+}
+
+// runQueuedCommand executes the command's function, recovering from any panic in it (so the worker keeps running and
+// the audio engine is resumed) and returning it as an error.
+func runQueuedCommand(cmd *queuedCommand) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("error: queued function panicked: %v", r))
+		}
+	}()
+	return cmd.run()
+}
+
+// stopQueue stops the SunvoxChannel's background worker goroutine (if it's running) and waits for it to exit.
+// Any commands still waiting are resolved with ErrorChannelClosed.
+func (s *SunvoxChannel) stopQueue() {
+
+	s.queue.mutex.Lock()
+	worker := s.queue.worker
+	s.queue.worker = nil
+	s.queue.mutex.Unlock()
+
+	if worker == nil {
+		return
+	}
+
+	close(worker.quit)
+	<-worker.finished
+
+}
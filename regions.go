@@ -0,0 +1,397 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+var ErrorLoopRegionNotFound = errors.New("error: no loop region exists with the given name")
+
+// LoopRegion represents a stretch of lines in a SunvoxChannel's project that playback can loop within, without changing
+// the project itself. Loop regions are added to a channel through SunvoxChannel.SetLoopRegion(), and only the active
+// region (see SunvoxChannel.SetActiveLoopRegion()) affects playback.
+type LoopRegion struct {
+	Name  string
+	Start int // The first line of the region
+	End   int // The line at which playback jumps back to Start; this line isn't played itself
+
+	// If Confine is true, playback is kept within the region while it's active: if the playhead is anywhere outside of
+	// the region (for example, after PlayFromBeginning() or Seek()), it jumps to Start.
+	// Otherwise, playback can run into the region from before it and leave it by seeking past End.
+	Confine bool
+}
+
+// Length returns the length of the LoopRegion in lines.
+func (r LoopRegion) Length() int {
+	return r.End - r.Start
+}
+
+// Contains returns if the given line is within the LoopRegion.
+func (r LoopRegion) Contains(line int) bool {
+	return line >= r.Start && line < r.End
+}
+
+// SetLoopRegion adds the given LoopRegion to the SunvoxChannel, replacing any region that has the same name.
+// If the replaced region is active, the new one takes effect immediately.
+//
+// Adding a region doesn't affect playback by itself; use SetActiveLoopRegion() or SwitchLoopRegion() to loop it.
+func (s *SunvoxChannel) SetLoopRegion(region LoopRegion) error {
+
+	if region.Name == "" {
+		return errors.New("error setting loop region; the region must have a name")
+	}
+
+	if region.Start < 0 || region.End <= region.Start {
+		return errors.New(fmt.Sprintf("error setting loop region %s; the region's lines (%d to %d) are invalid", region.Name, region.Start, region.End))
+	}
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	s.recordLoopRegions()
+	s.regions[region.Name] = region
+
+	return nil
+
+}
+
+// RemoveLoopRegion removes the LoopRegion with the given name from the SunvoxChannel. If the region is active, playback
+// continues normally from wherever the playhead is.
+func (s *SunvoxChannel) RemoveLoopRegion(name string) {
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	if _, ok := s.regions[name]; !ok {
+		return
+	}
+
+	s.recordLoopRegions()
+	delete(s.regions, name)
+
+	if s.activeRegion == name {
+		s.activeRegion = ""
+	}
+
+	if s.switchingRegion && s.pendingRegion == name {
+		s.switchingRegion = false
+		s.pendingRegion = ""
+	}
+
+}
+
+// LoopRegion returns the LoopRegion with the given name, and whether it exists.
+func (s *SunvoxChannel) LoopRegion(name string) (LoopRegion, bool) {
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+	region, ok := s.regions[name]
+	return region, ok
+}
+
+// LoopRegions returns all of the SunvoxChannel's LoopRegions, ordered by their starting lines.
+func (s *SunvoxChannel) LoopRegions() []LoopRegion {
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	regions := make([]LoopRegion, 0, len(s.regions))
+	for _, region := range s.regions {
+		regions = append(regions, region)
+	}
+
+	slices.SortFunc(regions, func(a, b LoopRegion) int {
+		if a.Start != b.Start {
+			return a.Start - b.Start
+		}
+		if a.End != b.End {
+			return a.End - b.End
+		}
+		if a.Name < b.Name {
+			return -1
+		}
+		return 1
+	})
+
+	return regions
+
+}
+
+// SetActiveLoopRegion immediately makes the LoopRegion with the given name the active one, cancelling any switch started
+// through SwitchLoopRegion(). If name is empty, no region is active, and playback continues normally from wherever the
+// playhead is. If no region exists with the given name, ErrorLoopRegionNotFound is returned.
+//
+// While a region is active, the engine's poller (see SunvoxEngine.SetUpdateMode()) watches the playhead, and as it
+// approaches the region's End, a jump back to its Start is sent to Sunvox ahead of time with a timestamp (accounting for
+// the engine's latency; see SunvoxEngine.Latency()), so that End itself isn't played. If the poller falls too far behind
+// to schedule the jump in time (for example, with long frames in UpdateModeManual), playback is rewound once the
+// playhead is seen past End instead (carrying over however far it went past, so the loop stays in time). On the last
+// pass of a loop limit (see SetLoopLimit()), playback is stopped once the playhead is seen past End.
+//
+// An EventLooped event is sent each time the region loops, so OnLoop(), LoopCount() and SetLoopLimit() work with loop
+// regions as well. Note that the region's lines should be within the song itself, as a song that doesn't loop stops at
+// its end before the region can loop it. As a scheduled jump can't be taken back, changing regions or seeking just
+// before the active region's End may still see playback jump back to its Start.
+func (s *SunvoxChannel) SetActiveLoopRegion(name string) error {
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	if _, ok := s.regions[name]; name != "" && !ok {
+		return ErrorLoopRegionNotFound
+	}
+
+	s.recordLoopRegions()
+	s.activeRegion = name
+	s.switchingRegion = false
+	s.pendingRegion = ""
+
+	engine.startPolling()
+
+	return nil
+
+}
+
+// SwitchLoopRegion makes the LoopRegion with the given name the active one once the playhead next reaches the end of the
+// active region; playback then jumps to the start of the new region rather than back to the start of the current one.
+// If name is empty, playback continues past the end of the active region instead, and no region is active afterwards.
+// If no region is active (or playback is stopped), the switch happens immediately.
+// If no region exists with the given name, ErrorLoopRegionNotFound is returned.
+func (s *SunvoxChannel) SwitchLoopRegion(name string) error {
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	if _, ok := s.regions[name]; name != "" && !ok {
+		return ErrorLoopRegionNotFound
+	}
+
+	s.recordLoopRegions()
+
+	if s.activeRegion == "" || !s.playing.Load() {
+		s.activeRegion = name
+		s.switchingRegion = false
+		s.pendingRegion = ""
+	} else {
+		s.switchingRegion = true
+		s.pendingRegion = name
+	}
+
+	engine.startPolling()
+
+	return nil
+
+}
+
+// ClearLoopRegion deactivates the active LoopRegion, if there is one, so playback continues normally from wherever the
+// playhead is. This is a shortcut for SetActiveLoopRegion("").
+func (s *SunvoxChannel) ClearLoopRegion() {
+	s.SetActiveLoopRegion("")
+}
+
+// ActiveLoopRegion returns the active LoopRegion, and whether there is one.
+func (s *SunvoxChannel) ActiveLoopRegion() (LoopRegion, bool) {
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+	region, ok := s.regions[s.activeRegion]
+	return region, ok
+}
+
+// PendingLoopRegion returns the name of the LoopRegion that will become active at the end of the active one
+// (see SwitchLoopRegion()), and whether a switch is pending at all.
+func (s *SunvoxChannel) PendingLoopRegion() (string, bool) {
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+	return s.pendingRegion, s.switchingRegion
+}
+
+// confinedRegionStart returns the start of the active LoopRegion if it confines playback.
+func (s *SunvoxChannel) confinedRegionStart() (int, bool) {
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+	region, ok := s.regions[s.activeRegion]
+	if !ok || !region.Confine {
+		return 0, false
+	}
+	return region.Start, true
+}
+
+// regionResult indicates what updateLoopRegion did with the playhead.
+type regionResult int
+
+const (
+	regionNone    regionResult = iota // Nothing was done
+	regionEntered                     // The playhead was moved into a confining region
+	regionLooped                      // The playhead reached the end of the region and was rewound
+	regionEnded                       // The playhead reached the end of the region on the last pass of a loop limit, so playback was stopped
+)
+
+// updateLoopRegion schedules a jump back to the start of the active LoopRegion as the playhead approaches its end, and
+// rewinds playback if the playhead has reached the end without one (or has left a confining region) since it was last
+// polled. prevLine is the line from the previous poll, and seeked is whether playback was started or seeked since then.
+// updateLoopRegion returns the current line afterwards.
+func (s *SunvoxChannel) updateLoopRegion(prevLine, line int, seeked bool) (int, regionResult) {
+
+	s.regionMutex.Lock()
+	defer s.regionMutex.Unlock()
+
+	now := time.Now()
+	pollInterval := now.Sub(s.regionPollTime)
+	s.regionPollTime = now
+
+	// The scheduled jump has happened once the playhead moves backwards by itself, or into the region it jumps to from
+	// outside of it (when switching to a region later in the song)
+	if target, ok := s.regions[s.regionJumpTo]; s.regionJump && ok && !seeked &&
+		(line < prevLine || (target.Contains(line) && !target.Contains(prevLine) && prevLine != target.Start-1)) {
+		s.regionJump = false
+		if s.switchingRegion && s.pendingRegion == s.regionJumpTo {
+			s.activeRegion = s.pendingRegion
+			s.switchingRegion = false
+			s.pendingRegion = ""
+		}
+		return line, regionLooped
+	}
+
+	region, ok := s.regions[s.activeRegion]
+	if !ok {
+		return line, regionNone
+	}
+
+	// Sunvox reports the line the playhead is on a little after it gets there, so the playhead can be seen at End for a
+	// moment before the scheduled jump shows up.
+	missedJump := false
+
+	if s.regionJump {
+		if now.Before(s.regionJumpTime.Add(engine.Latency()*2 + max(engine.PollResolution(), pollInterval))) {
+			return line, regionNone
+		}
+		// The jump didn't happen in time (for example, because the audio thread fell behind), so the playhead is rewound
+		// instead
+		s.regionJump = false
+		missedJump = true
+	}
+
+	// If the playhead went past End (or wrapped around back to the beginning of the song from within the region) by
+	// itself, the region loops.
+	crossed := !seeked && (prevLine < region.End || missedJump) && (line >= region.End || (line < prevLine && prevLine >= region.Start))
+
+	s.loopMutex.Lock()
+	lastPass := s.loopLimit > 0 && s.loopCount >= s.loopLimit-1
+	s.loopMutex.Unlock()
+
+	if !crossed {
+
+		// Right after being rewound, Sunvox reports the line before the one it was rewound to, so that line is considered
+		// to be within the region as well.
+		if region.Confine && (line < region.Start-1 || line >= region.End) {
+			s.rewindRegion(region.Start)
+			return s.CurrentLine(), regionEntered
+		}
+
+		if !lastPass && line >= region.Start-1 && line < region.End {
+			s.scheduleRegionJump(region, line, pollInterval)
+		}
+
+		return line, regionNone
+
+	}
+
+	if lastPass {
+		// playing isn't cleared so that the channel's state becomes StateEnded, as it would at the end of a song
+		s.pauseEngine()
+		stop(s.Index)
+		s.resumeEngine()
+		return line, regionEnded
+	}
+
+	overshoot := 0
+	if line >= region.End {
+		overshoot = line - region.End
+	}
+
+	if s.switchingRegion {
+
+		s.activeRegion = s.pendingRegion
+		s.switchingRegion = false
+		s.pendingRegion = ""
+
+		next, ok := s.regions[s.activeRegion]
+		if !ok {
+			return line, regionNone
+		}
+		region = next
+
+	}
+
+	s.rewindRegion(region.Start + overshoot%region.Length())
+
+	return s.CurrentLine(), regionLooped
+
+}
+
+// scheduleRegionJump sends Sunvox a timestamped jump to the start of the region that follows the given one (the pending
+// one if a switch is pending, or the same one otherwise) to be heard when the playhead reaches the region's End, if that's
+// soon enough that the jump can't be made later on. regionMutex must be held.
+func (s *SunvoxChannel) scheduleRegionJump(region LoopRegion, line int, pollInterval time.Duration) {
+
+	next := region
+	if s.switchingRegion {
+		pending, ok := s.regions[s.pendingRegion]
+		if !ok {
+			// Playback continues past End when switching to no region
+			return
+		}
+		next = pending
+	}
+
+	lpm := float64(s.LPM())
+	if lpm <= 0 || math.IsInf(lpm, 0) {
+		return
+	}
+
+	// The fractional position is only used if it agrees with the line
+	position := s.CurrentPosition()
+	if int(math.Floor(position)) != line {
+		position = float64(line)
+	}
+
+	secondsPerLine := 60 / lpm
+	until := time.Duration((float64(region.End) - position) * secondsPerLine * float64(time.Second))
+
+	// Sunvox jumps (effect 0x31) at the end of the line the jump is received on, like a jump in a pattern, so it's timed
+	// for the middle of the last line of the region
+	at := time.Now().Add(until - time.Duration(secondsPerLine/2*float64(time.Second)))
+
+	// The jump has to be sent before its timestamp (which is earlier than when it's heard by twice the latency), with
+	// room for the poll after next in case the next one is late
+	horizon := engine.Latency()*2 + max(engine.PollResolution(), min(pollInterval, time.Millisecond*250))*2
+
+	if time.Until(at) > horizon {
+		return
+	}
+
+	s.eventMutex.Lock()
+	setEventT(s.Index, 1, timestampAt(at))
+	res := sendEvent(s.Index, 0, 0, 0, 0, 0x0031, next.Start)
+	s.restoreEventTimestamp()
+	s.eventMutex.Unlock()
+
+	if res < 0 {
+		return
+	}
+
+	s.regionJump = true
+	s.regionJumpTo = next.Name
+	s.regionJumpTime = time.Now().Add(until)
+
+}
+
+// rewindRegion rewinds playback to the given line for a LoopRegion. This isn't counted as seeking, as the poller
+// handles the jump itself.
+func (s *SunvoxChannel) rewindRegion(line int) {
+	s.pauseEngine()
+	rewind(s.Index, line)
+	s.resumeEngine()
+}
@@ -0,0 +1,213 @@
+package sunvoxgo
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// TrackEvent represents an event to be sent to a SunvoxChannel, with the same values as SunvoxChannel.SendEvent() takes.
+type TrackEvent struct {
+	Track           int // The event track to send the event on (from 0 to EventTrackCount-1)
+	Note            int // The note (C5 is 61), or one of the NoteCommand constants; 0 means no note
+	Velocity        int // The velocity (from 1 to 129); 0 means the default velocity
+	Module          int // The module's index + 1; 0 means no module
+	Controller      int // The controller / effect
+	ControllerValue int // The controller / effect value
+}
+
+const (
+	scheduledPending = iota
+	scheduledSent
+	scheduledCanceled
+)
+
+// ScheduledEvent represents a TrackEvent scheduled through an EventScheduler.
+type ScheduledEvent struct {
+	Event TrackEvent
+	Time  time.Time // When the event should be heard
+
+	scheduler *EventScheduler
+	state     int // Guarded by the scheduler's mutex
+}
+
+// Cancel cancels the ScheduledEvent, returning true if it was canceled before it was sent to Sunvox. Events that have
+// already been sent can't be canceled.
+func (e *ScheduledEvent) Cancel() bool {
+
+	e.scheduler.mutex.Lock()
+	defer e.scheduler.mutex.Unlock()
+
+	if e.state != scheduledPending {
+		return false
+	}
+
+	e.state = scheduledCanceled
+	e.scheduler.events = slices.DeleteFunc(e.scheduler.events, func(other *ScheduledEvent) bool { return other == e })
+
+	return true
+
+}
+
+// IsSent returns if the ScheduledEvent has been sent to Sunvox.
+func (e *ScheduledEvent) IsSent() bool {
+	e.scheduler.mutex.Lock()
+	defer e.scheduler.mutex.Unlock()
+	return e.state == scheduledSent
+}
+
+// IsCanceled returns if the ScheduledEvent was canceled.
+func (e *ScheduledEvent) IsCanceled() bool {
+	e.scheduler.mutex.Lock()
+	defer e.scheduler.mutex.Unlock()
+	return e.state == scheduledCanceled
+}
+
+// EventScheduler sends events to a SunvoxChannel at precise times. Rather than being sent when the game gets around to
+// it (which jitters with the frame rate), each event is timestamped (see SunvoxChannel.SetEventTimestamp()), so that
+// Sunvox places it in its audio stream according to its time rather than according to when it was sent. (Sunvox applies
+// events at the start of the audio buffer containing their time, so they're as precise as the engine's buffer size allows.)
+//
+// Events are held by the EventScheduler until they're within its Horizon, and are then sent to Sunvox ahead of time.
+// This is done by the engine's poller (see SunvoxEngine.SetUpdateMode()), as well as whenever an event is scheduled.
+type EventScheduler struct {
+	Channel *SunvoxChannel
+
+	// Horizon is how far ahead of their time events are sent to Sunvox; events can't be canceled after they're sent.
+	// It must be longer than the time between updates of the engine (so, in UpdateModeManual, longer than a frame),
+	// or else events would be sent late. By default, it's 100 milliseconds.
+	Horizon time.Duration
+
+	mutex  sync.Mutex
+	events []*ScheduledEvent // Pending events, ordered by time
+}
+
+// NewEventScheduler creates a new EventScheduler to send events to the given SunvoxChannel.
+func NewEventScheduler(channel *SunvoxChannel) *EventScheduler {
+	return &EventScheduler{
+		Channel: channel,
+		Horizon: time.Millisecond * 100,
+	}
+}
+
+// At schedules the given event to be heard at the given time. Sunvox renders audio ahead of what can be heard, so
+// the event is timestamped earlier by twice the engine's latency (see SunvoxEngine.Latency()), as Sunvox delays
+// timestamped events by that much. If the time is sooner than that, the event is heard as soon as possible.
+func (s *EventScheduler) At(at time.Time, event TrackEvent) *ScheduledEvent {
+
+	scheduled := &ScheduledEvent{
+		Event:     event,
+		Time:      at,
+		scheduler: s,
+	}
+
+	s.mutex.Lock()
+
+	// Events scheduled for the same time are sent in the order they were scheduled
+	index, _ := slices.BinarySearchFunc(s.events, at, func(e *ScheduledEvent, t time.Time) int {
+		if e.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	s.events = slices.Insert(s.events, index, scheduled)
+
+	s.mutex.Unlock()
+
+	engine.addTask(s)
+
+	s.send(time.Now())
+
+	return scheduled
+
+}
+
+// After schedules the given event to be heard after the given delay. See At() for more information.
+func (s *EventScheduler) After(delay time.Duration, event TrackEvent) *ScheduledEvent {
+	return s.At(time.Now().Add(delay), event)
+}
+
+// Sequence schedules the given events to be heard at the given offsets from the start time, returning the
+// ScheduledEvents. The offsets and events are matched by index; extra offsets or events are ignored.
+func (s *EventScheduler) Sequence(start time.Time, offsets []time.Duration, events []TrackEvent) []*ScheduledEvent {
+	scheduled := make([]*ScheduledEvent, 0, min(len(offsets), len(events)))
+	for i := range min(len(offsets), len(events)) {
+		scheduled = append(scheduled, s.At(start.Add(offsets[i]), events[i]))
+	}
+	return scheduled
+}
+
+// CancelAll cancels all of the EventScheduler's pending events.
+func (s *EventScheduler) CancelAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, e := range s.events {
+		e.state = scheduledCanceled
+	}
+	s.events = nil
+}
+
+// Pending returns the number of events that haven't been sent to Sunvox yet.
+func (s *EventScheduler) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.events)
+}
+
+// Close cancels all of the EventScheduler's pending events and stops it from being updated by the engine.
+func (s *EventScheduler) Close() {
+	s.CancelAll()
+	engine.removeTask(s)
+}
+
+// send sends the events that are within the EventScheduler's horizon to Sunvox.
+func (s *EventScheduler) send(now time.Time) {
+
+	s.mutex.Lock()
+
+	due := 0
+	for due < len(s.events) && s.events[due].Time.Before(now.Add(s.Horizon)) {
+		s.events[due].state = scheduledSent
+		due++
+	}
+
+	events := slices.Clone(s.events[:due])
+	s.events = s.events[due:]
+
+	s.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	channel := s.Channel
+
+	// The timestamp applies to every event sent until it's reset, so other events can't be sent in between
+	channel.eventMutex.Lock()
+	defer channel.eventMutex.Unlock()
+
+	for _, e := range events {
+		setEventT(channel.Index, 1, timestampAt(e.Time))
+		sendEvent(channel.Index, e.Event.Track, e.Event.Note, e.Event.Velocity, e.Event.Module, e.Event.Controller, e.Event.ControllerValue)
+	}
+
+	// Any timestamp set by the user through SunvoxChannel.SetEventTimestamp() applies again afterwards
+	channel.restoreEventTimestamp()
+
+}
+
+// timestampAt returns the Sunvox timestamp for an event to be heard at the given time. Sunvox delays timestamped events
+// by twice the engine's latency (see SunvoxEngine.Latency()), so the timestamp is earlier by that much.
+func timestampAt(at time.Time) uint32 {
+	delta := time.Until(at) - engine.Latency()*2
+	// Sunvox's ticks wrap around, so the offset is added with wrapping arithmetic
+	return getTicks() + uint32(int64(delta.Seconds()*float64(getTicksPerSecond())))
+}
+
+func (s *EventScheduler) step(dt float32) bool {
+	s.send(time.Now())
+	// Once nothing is pending, the EventScheduler stops being updated until another event is scheduled (see At())
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.events) == 0
+}
@@ -0,0 +1,413 @@
+package sunvoxgo
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Step represents a step in a StepTrack.
+type Step struct {
+	Note     Note // The note to play; if it's NoteNone, no note is played, though a controller can still be set
+	Velocity int  // The note's velocity (from 1 to 129); 0 means the default velocity
+
+	Controller      int // The controller / effect to send with the step; 0 means none
+	ControllerValue int // The controller / effect value to send with the step
+
+	// Probability is the chance (from 0 to 1) that the step plays each time it's reached; 0 (the default) is treated as 1,
+	// so the step always plays.
+	Probability float64
+
+	// Gate is how long the note is held before a note off is sent, as a fraction of the step's length (so 0.5 holds the
+	// note for half of the step, up to 1 for the whole step). If it's 0 (the default), no note off is sent, so the note
+	// rings until the next note on the track.
+	Gate float64
+}
+
+// StepTrack represents a looping row of steps played on a module by a StepSequencer.
+// Its steps can be edited at any time, including while the StepSequencer is running.
+type StepTrack struct {
+	Module     *SunvoxModule
+	EventTrack int // The event track notes are sent on; see SunvoxChannel.SendEvent()
+
+	sequencer     *StepSequencer
+	reservedTrack int // The event track reserved for the StepTrack on the channel, or -1 if there's none
+	steps         []Step
+	muted         bool
+}
+
+// Steps returns a copy of the StepTrack's steps.
+func (t *StepTrack) Steps() []Step {
+	t.sequencer.mutex.Lock()
+	defer t.sequencer.mutex.Unlock()
+	return slices.Clone(t.steps)
+}
+
+// SetSteps sets the StepTrack's steps. The number of steps is the length of the StepTrack's loop, so tracks of different
+// lengths can be used for polyrhythms.
+func (t *StepTrack) SetSteps(steps []Step) {
+	t.sequencer.mutex.Lock()
+	defer t.sequencer.mutex.Unlock()
+	t.steps = slices.Clone(steps)
+}
+
+// SetStep sets the step at the given index, if it exists.
+func (t *StepTrack) SetStep(index int, step Step) {
+	t.sequencer.mutex.Lock()
+	defer t.sequencer.mutex.Unlock()
+	if index >= 0 && index < len(t.steps) {
+		t.steps[index] = step
+	}
+}
+
+// SetMuted sets whether the StepTrack is muted. A muted StepTrack keeps its place, but plays no steps.
+func (t *StepTrack) SetMuted(muted bool) {
+	t.sequencer.mutex.Lock()
+	defer t.sequencer.mutex.Unlock()
+	t.muted = muted
+}
+
+// IsMuted returns if the StepTrack is muted.
+func (t *StepTrack) IsMuted() bool {
+	t.sequencer.mutex.Lock()
+	defer t.sequencer.mutex.Unlock()
+	return t.muted
+}
+
+// reserve reserves the StepTrack's event track on the channel, unless it's reserved already (as when StepTracks share it).
+func (t *StepTrack) reserve() {
+	if t.reservedTrack < 0 && t.sequencer.Channel.reserveEventTrack(t.EventTrack) {
+		t.reservedTrack = t.EventTrack
+	}
+}
+
+// release releases the event track reserved for the StepTrack, if there is one.
+func (t *StepTrack) release() {
+	t.sequencer.Channel.releaseEventTrack(t.reservedTrack)
+	t.reservedTrack = -1
+}
+
+// StepSequencer plays looping grids of steps on a SunvoxChannel's modules from Go, without writing into patterns.
+//
+// Steps are counted in lines from the beginning of the song, so while the SunvoxChannel is playing, the StepSequencer
+// stays in phase with its playhead (following its tempo, loops and seeks). While the channel isn't playing, the StepSequencer
+// keeps going by itself at its own tempo (see SetTempo()). Steps just after a loop point are sent once the playhead has
+// looped, so they may be slightly late.
+//
+// Steps are sent ahead of time with timestamps through an EventScheduler, so they play precisely regardless of how often
+// the engine is updated. Edits to the steps take effect for steps that haven't been sent yet.
+type StepSequencer struct {
+	Channel *SunvoxChannel
+
+	mutex      sync.Mutex
+	tracks     []*StepTrack
+	stepLength float64 // How many lines each step lasts
+	swing      float64
+	running    bool
+
+	clock     lineClock
+	scheduler *EventScheduler
+	until     float64 // The position (in lines) up to which steps have been scheduled
+}
+
+// NewStepSequencer creates a new StepSequencer to play steps on the given SunvoxChannel. By default, each step lasts one line.
+func NewStepSequencer(channel *SunvoxChannel) *StepSequencer {
+	scheduler := NewEventScheduler(channel)
+	// The scheduler doesn't need to be updated until the StepSequencer schedules steps on it (which adds it back to the
+	// engine), and it's removed from the engine again once the StepSequencer stops
+	engine.removeTask(scheduler)
+	return &StepSequencer{
+		Channel:    channel,
+		stepLength: 1,
+		scheduler:  scheduler,
+	}
+}
+
+// AddTrack adds a StepTrack to the StepSequencer to play the given steps on the given module, returning it.
+// Each StepTrack is given its own event track (counting down from the last one that isn't reserved), which is reserved on
+// the SunvoxChannel while the StepSequencer is running so that Instruments don't play on it. If all of the channel's
+// event tracks are taken already, StepTracks share them.
+//
+// A SunvoxChannel only has EventTrackCount (16) event tracks, and Instruments can't steal voices from StepTracks, so
+// while StepTracks reserve all of them, Instruments on the channel can't play any notes (see Instrument.NoteOn()).
+//
+// The event track can be changed by setting StepTrack.EventTrack; the new track is reserved the next time the
+// StepSequencer starts.
+func (s *StepSequencer) AddTrack(module *SunvoxModule, steps []Step) *StepTrack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	taken := make([]int, 0, len(s.tracks))
+	for _, track := range s.tracks {
+		taken = append(taken, track.EventTrack)
+	}
+
+	eventTrack := s.Channel.unreservedEventTrack(taken)
+	if eventTrack < 0 {
+		eventTrack = EventTrackCount - 1 - len(s.tracks)%EventTrackCount
+	}
+
+	track := &StepTrack{
+		Module:        module,
+		EventTrack:    eventTrack,
+		sequencer:     s,
+		reservedTrack: -1,
+		steps:         slices.Clone(steps),
+	}
+
+	if s.running {
+		track.reserve()
+	}
+
+	s.tracks = append(s.tracks, track)
+	return track
+}
+
+// RemoveTrack removes the given StepTrack from the StepSequencer, releasing its event track for Instruments to use.
+func (s *StepSequencer) RemoveTrack(track *StepTrack) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !slices.Contains(s.tracks, track) {
+		return
+	}
+	s.tracks = slices.DeleteFunc(s.tracks, func(other *StepTrack) bool { return other == track })
+	track.release()
+}
+
+// Tracks returns the StepSequencer's StepTracks.
+func (s *StepSequencer) Tracks() []*StepTrack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.tracks)
+}
+
+// SetStepLength sets how many lines each step lasts (so with a TPL of 6, a step length of 1 makes each step a 16th note).
+// If stepLength is less than or equal to 0, it's set to 1.
+func (s *StepSequencer) SetStepLength(stepLength float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stepLength <= 0 {
+		stepLength = 1
+	}
+	s.stepLength = stepLength
+}
+
+// StepLength returns how many lines each step lasts.
+func (s *StepSequencer) StepLength() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stepLength
+}
+
+// SetSwing sets how much every other step is delayed, as a fraction of a step (from 0, straight, to 1). A swing of
+// 1/3 gives a triplet feel.
+func (s *StepSequencer) SetSwing(swing float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.swing = min(max(swing, 0), 1)
+}
+
+// Swing returns how much every other step is delayed, as a fraction of a step.
+func (s *StepSequencer) Swing() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.swing
+}
+
+// SetTempo sets the tempo the StepSequencer runs at while its SunvoxChannel isn't playing. If bpm or tpl are less than
+// or equal to 0 (the default), the channel's BPM or TPL are used.
+func (s *StepSequencer) SetTempo(bpm float64, tpl int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock.bpm = bpm
+	s.clock.tpl = tpl
+}
+
+// Start starts the StepSequencer, reserving its tracks' event tracks (see AddTrack()). If the SunvoxChannel is playing,
+// the StepSequencer joins in at its playhead; otherwise, it starts from the first step.
+func (s *StepSequencer) Start() {
+
+	s.mutex.Lock()
+
+	if s.running {
+		s.mutex.Unlock()
+		return
+	}
+
+	s.running = true
+	s.clock.reset(time.Now())
+	s.until = math.Inf(-1)
+
+	for _, track := range s.tracks {
+		track.reserve()
+	}
+
+	s.mutex.Unlock()
+
+	engine.addTask(s)
+
+	s.update(time.Now())
+
+}
+
+// Stop stops the StepSequencer, canceling any steps that haven't been sent to Sunvox yet and turning off its tracks' notes.
+// Its tracks' event tracks are released for Instruments to use until it's started again.
+func (s *StepSequencer) Stop() {
+
+	s.mutex.Lock()
+	if !s.running {
+		s.mutex.Unlock()
+		return
+	}
+	s.running = false
+	tracks := slices.Clone(s.tracks)
+	for _, track := range tracks {
+		track.release()
+	}
+	s.mutex.Unlock()
+
+	engine.removeTask(s)
+	s.scheduler.Close()
+
+	for _, track := range tracks {
+		s.Channel.SendEvent(track.EventTrack, NoteCommandNoteOff, 0, track.Module.Index+1, 0, 0)
+	}
+
+}
+
+// IsRunning returns if the StepSequencer is running.
+func (s *StepSequencer) IsRunning() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.running
+}
+
+// CurrentStep returns the step the StepSequencer is on (counting from the beginning of the song, so it's not wrapped to the
+// length of any StepTrack), including how far into the step it is.
+func (s *StepSequencer) CurrentStep() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.clock.line / s.stepLength
+}
+
+func (s *StepSequencer) step(dt float32) bool {
+	s.update(time.Now())
+	return false
+}
+
+// update schedules the steps that will be reached before the next update.
+func (s *StepSequencer) update(now time.Time) {
+
+	channel := s.Channel
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	wasSynced := s.clock.synced
+	lastLine := s.clock.line
+
+	line, lpm, ok := s.clock.update(channel, now)
+	if !ok {
+		return
+	}
+
+	latency := time.Duration(0)
+
+	if s.clock.synced {
+
+		// The song's audio is heard later than it's rendered, so steps are delayed to match
+		latency = engine.Latency()
+
+		// If the playhead jumped (from looping or seeking), the steps scheduled for where it was are canceled; small steps
+		// backwards are allowed, as the interpolated position can overshoot slightly
+		if !wasSynced || line < lastLine-1 || line > s.until+s.stepLength {
+			s.scheduler.CancelAll()
+			s.until = math.Inf(-1)
+		}
+
+	}
+
+	if math.IsInf(s.until, -1) {
+		// Steps that start right at the current position are played as well
+		s.until = line - 1e-6
+	}
+
+	// Steps are scheduled as far ahead as the scheduler sends them, so that edits take effect as late as possible
+	end := line + s.scheduler.Horizon.Minutes()*lpm
+
+	// Steps past the end of the song or the active loop region won't be reached while playing
+	if s.clock.synced {
+		end = min(end, float64(channel.noteScanLimit())-1e-6)
+	}
+
+	if end <= s.until {
+		return
+	}
+
+	secondsPerLine := 60 / lpm
+	stepSeconds := s.stepLength * secondsPerLine
+
+	// Swing can only delay steps, so a step from before the window could land within it
+	first := int(math.Floor(s.until/s.stepLength)) - 1
+	last := int(math.Ceil(end / s.stepLength))
+
+	for index := max(first, 0); index <= last; index++ {
+
+		stepLine := float64(index) * s.stepLength
+		if index%2 == 1 {
+			stepLine += s.swing * s.stepLength
+		}
+
+		if stepLine <= s.until || stepLine > end {
+			continue
+		}
+
+		at := now.Add(time.Duration((stepLine-line)*secondsPerLine*float64(time.Second)) + latency)
+
+		for _, track := range s.tracks {
+
+			if track.muted || len(track.steps) == 0 {
+				continue
+			}
+
+			step := track.steps[index%len(track.steps)]
+
+			if step.Note == NoteNone && step.Controller == 0 {
+				continue
+			}
+
+			if step.Probability > 0 && step.Probability < 1 && rand.Float64() >= step.Probability {
+				continue
+			}
+
+			module := track.Module.Index + 1
+
+			s.scheduler.At(at, TrackEvent{
+				Track:           track.EventTrack,
+				Note:            int(step.Note),
+				Velocity:        step.Velocity,
+				Module:          module,
+				Controller:      step.Controller,
+				ControllerValue: step.ControllerValue,
+			})
+
+			if step.Note.IsValid() && step.Gate > 0 {
+				s.scheduler.At(at.Add(time.Duration(min(step.Gate, 1)*stepSeconds*float64(time.Second))), TrackEvent{
+					Track:  track.EventTrack,
+					Note:   NoteCommandNoteOff,
+					Module: module,
+				})
+			}
+
+		}
+
+	}
+
+	s.until = end
+
+}
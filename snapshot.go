@@ -0,0 +1,172 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrorNotSnapshot = errors.New("error: the pattern data isn't a snapshot")
+var ErrorPatternResized = errors.New("error: the pattern's size doesn't match the snapshot's")
+
+// Snapshot returns a copy of the pattern's data, taken while the channel is locked. Unlike the data returned by Data(),
+// the snapshot is owned by Go, so it stays valid if the pattern is resized or removed, and it can be read and edited
+// (including with SunvoxPatternData's transform functions) without racing with playback. Edits to a snapshot don't
+// affect the pattern until the snapshot is written back with Commit().
+func (p *SunvoxPattern) Snapshot() (*SunvoxPatternData, error) {
+
+	if err := p.Channel.Lock(); err != nil {
+		return nil, err
+	}
+	defer p.Channel.Unlock()
+
+	data, err := p.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SunvoxPatternData{
+		trackCount: data.trackCount,
+		original:   slices.Clone(data.Data),
+		Data:       slices.Clone(data.Data),
+	}, nil
+
+}
+
+// Commit writes the cells of the snapshot (see Snapshot()) that have changed since it was taken (or last committed)
+// back to the pattern while the channel is locked, returning the number of cells written. Cells the snapshot didn't
+// change are left as they are in the pattern, even if they've been changed there since the snapshot was taken.
+// If the pattern doesn't have the same number of tracks and lines as the snapshot, ErrorPatternResized is returned and
+// nothing is written; if the data isn't a snapshot, ErrorNotSnapshot is returned.
+//
+// If the channel's edit journal is enabled, the cells written are recorded as a single edit step.
+func (p *SunvoxPattern) Commit(snapshot *SunvoxPatternData) (int, error) {
+
+	if snapshot == nil || snapshot.original == nil {
+		return 0, ErrorNotSnapshot
+	}
+
+	if err := p.Channel.Lock(); err != nil {
+		return 0, err
+	}
+	defer p.Channel.Unlock()
+
+	lineCount, err := p.LineCount()
+	if err != nil {
+		return 0, err
+	}
+
+	trackCount, err := p.TrackCount()
+	if err != nil {
+		return 0, err
+	}
+
+	if trackCount != snapshot.trackCount || lineCount != snapshot.LineCount() {
+		return 0, ErrorPatternResized
+	}
+
+	p.Channel.BeginEditStep("Edit pattern")
+	defer p.Channel.EndEditStep()
+
+	count := 0
+
+	for i, cell := range snapshot.Data {
+
+		if cell == snapshot.original[i] {
+			continue
+		}
+
+		if err := p.setEvent(i%trackCount, i/trackCount, cell); err != nil {
+			return count, err
+		}
+
+		snapshot.original[i] = cell
+		count++
+
+	}
+
+	return count, nil
+
+}
+
+// Event returns the cell at the given track and line of the pattern, read while the channel is locked.
+// If the track or line is outside of the pattern, an error is returned.
+func (p *SunvoxPattern) Event(track, line int) (SunvoxPatternNoteData, error) {
+
+	if err := p.Channel.Lock(); err != nil {
+		return SunvoxPatternNoteData{}, err
+	}
+	defer p.Channel.Unlock()
+
+	return p.event(track, line)
+
+}
+
+// SetEvent sets the cell at the given track and line of the pattern to the given cell while the channel is locked.
+// If the track or line is outside of the pattern, an error is returned.
+func (p *SunvoxPattern) SetEvent(track, line int, cell SunvoxPatternNoteData) error {
+
+	if err := p.Channel.Lock(); err != nil {
+		return err
+	}
+	defer p.Channel.Unlock()
+
+	return p.setEvent(track, line, cell)
+
+}
+
+// event reads the cell at the given track and line of the pattern. The channel must be locked.
+func (p *SunvoxPattern) event(track, line int) (SunvoxPatternNoteData, error) {
+
+	var columns [5]int32
+
+	for column := range columns {
+		res := getPatternEvent(p.Channel.Index, p.Index, track, line, column)
+		if res < 0 {
+			return SunvoxPatternNoteData{}, errors.New(fmt.Sprintf("error getting the event at track %d, line %d of pattern %d in channel %d; error code %d", track, line, p.Index, p.Channel.Index, res))
+		}
+		columns[column] = res
+	}
+
+	return SunvoxPatternNoteData{
+		Note:            uint8(columns[0]),
+		Velocity:        uint8(columns[1]),
+		Module:          uint16(columns[2]),
+		Controller:      uint16(columns[3]),
+		ControllerValue: uint16(columns[4]),
+	}, nil
+
+}
+
+// setEvent sets the cell at the given track and line of the pattern, recording the change in the channel's edit
+// journal. The channel must be locked.
+func (p *SunvoxPattern) setEvent(track, line int, cell SunvoxPatternNoteData) error {
+
+	previous, err := p.event(track, line)
+	if err != nil {
+		return err
+	}
+
+	if previous == cell {
+		return nil
+	}
+
+	if err := p.writeEvent(track, line, cell); err != nil {
+		return err
+	}
+
+	p.Channel.recordEdit("Edit pattern", eventEdit(p, track, line, previous))
+
+	return nil
+
+}
+
+// writeEvent sets the cell at the given track and line of the pattern. The channel must be locked.
+func (p *SunvoxPattern) writeEvent(track, line int, cell SunvoxPatternNoteData) error {
+	res := setPatternEvent(p.Channel.Index, p.Index, track, line, int(cell.Note), int(cell.Velocity), int(cell.Module), int(cell.Controller), int(cell.ControllerValue))
+	if res < 0 {
+		return errors.New(fmt.Sprintf("error setting the event at track %d, line %d of pattern %d in channel %d; error code %d", track, line, p.Index, p.Channel.Index, res))
+	}
+	p.Channel.invalidateNotes(p.Index)
+	return nil
+}
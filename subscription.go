@@ -0,0 +1,109 @@
+package sunvoxgo
+
+import (
+	"context"
+)
+
+// Subscription represents a callback registered on a SunvoxChannel (for example, through OnCurrentLineChange()).
+// The callback runs until the Subscription is canceled, the callback itself asks to stop, the context passed when
+// registering it is canceled, or the channel closes.
+type Subscription struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Cancel cancels the Subscription. Cancel returns immediately; use Done() to wait for the callback to stop running.
+// Calling Cancel multiple times is safe.
+func (s *Subscription) Cancel() {
+	s.cancel()
+}
+
+// Done returns a channel that is closed once the Subscription has stopped and its callback will no longer be called.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Context returns the context for the Subscription; it's canceled as soon as the Subscription is canceled for any reason.
+func (s *Subscription) Context() context.Context {
+	return s.ctx
+}
+
+// newSubscription creates a Subscription that is tracked by the SunvoxChannel. The Subscription is canceled when
+// canceled directly, when parent is canceled (if it isn't nil), or when the channel closes. onDone (if it isn't nil)
+// is called once the Subscription is canceled, before its Done() channel is closed.
+func (s *SunvoxChannel) newSubscription(parent context.Context, onDone func()) *Subscription {
+
+	s.subscriptionMutex.Lock()
+	defer s.subscriptionMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	sub := &Subscription{
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	// If the channel has already been closed, the Subscription is canceled immediately
+	closed := s.ctx.Err() != nil
+
+	if !closed {
+		s.subscriptions.Add(1)
+	}
+
+	stopParent := func() bool { return false }
+	if parent != nil {
+		stopParent = context.AfterFunc(parent, cancel)
+	}
+
+	context.AfterFunc(ctx, func() {
+		stopParent()
+		if onDone != nil {
+			onDone()
+		}
+		close(sub.done)
+		if !closed {
+			s.subscriptions.Done()
+		}
+	})
+
+	if closed {
+		cancel()
+	}
+
+	return sub
+
+}
+
+// replaceSubscription cancels the subscription previously set for the given name (if any) and stores the new one
+// in its place. It's used for the SetOn* functions, which only allow one callback at a time.
+func (s *SunvoxChannel) replaceSubscription(name string, sub *Subscription) {
+	s.subscriptionMutex.Lock()
+	defer s.subscriptionMutex.Unlock()
+
+	if existing, ok := s.namedSubscriptions[name]; ok {
+		existing.Cancel()
+	}
+
+	if sub == nil {
+		delete(s.namedSubscriptions, name)
+	} else {
+		s.namedSubscriptions[name] = sub
+	}
+}
+
+// cancelSubscriptions cancels all of the SunvoxChannel's subscriptions and waits for them to finish. If callbacks are
+// being called at the time (as when it's called from a callback), the subscriptions can't finish until the callbacks
+// return, so they aren't waited for; the rest of the callbacks for the current poll are skipped, as their subscriptions
+// have been canceled.
+func (s *SunvoxChannel) cancelSubscriptions() {
+	s.subscriptionMutex.Lock()
+	s.cancel()
+	clear(s.namedSubscriptions)
+	s.subscriptionMutex.Unlock()
+
+	if !engine.inCallbacks() {
+		s.subscriptions.Wait()
+	}
+}
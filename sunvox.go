@@ -1,14 +1,16 @@
 package sunvoxgo
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
-	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -32,6 +34,11 @@ const (
 	ModuleFlagBypass
 )
 
+const (
+	moduleOutputsOffset = 16 + 8
+	moduleOutputsMask   = 255 << moduleOutputsOffset
+)
+
 const (
 	NoteCommandNoteOff     = 128 + iota
 	NoteCommandAllNotesOff // send "note off" to all modules;
@@ -46,6 +53,7 @@ type InitConfig struct {
 	SampleRate  int
 	Flags       uint32
 	ExtraString string
+	bufferSize  int
 }
 
 func NewInitConfig() *InitConfig {
@@ -58,6 +66,7 @@ func (i *InitConfig) WithBuffer(bufferSize int) *InitConfig {
 		i.ExtraString += "|"
 	}
 	i.ExtraString += "buffer=" + strconv.Itoa(bufferSize)
+	i.bufferSize = bufferSize
 	return i
 }
 
@@ -159,7 +168,7 @@ flags - set of flags SV_INIT_FLAG_*;
 
 Returns the version or an error string otherwise
 */
-var initEngine func(config string, sampleRate int, flags uint32) int32
+var initEngine func(config string, sampleRate int, channels int, flags uint32) int32
 var deinitEngine func() int32
 
 // Opens a project slot; any number from 0 to 15 (that hasn't been used before).
@@ -179,6 +188,7 @@ var loadFileFromMemory func(slotNum int, data []byte, dataSize uint32) int32
 
 var setSlotVolume func(slotNum int, volume int) int32
 var getCurrentLine func(slotNum int) int32
+var getCurrentLine2 func(slotNum int) int32                // Current line in fixed point format 27.5
 var getCurrentSignalLevel func(slotNum, channel int) uint8 // Ranges from 0 - 255
 var getSongName func(slotNum int) string
 var setSongName func(slotNum int, name string) int32
@@ -186,6 +196,7 @@ var getSongBPM func(slotNum int) int32
 var getSongTPL func(slotNum int) int32
 var getLengthFrames func(slotNum int) uint32
 var getLengthLines func(slotNum int) uint32
+var getTimeMap func(slotNum, startLine, lineCount int, dest *uint32, flags int) int32 // Fills dest with the BPM and TPL (or frame) of each line
 
 var play func(slotNum int) int32
 var playFromBeginning func(slotNum int) int32
@@ -212,12 +223,15 @@ var getPatternLineCount func(slotNum, patternNum int) int32  // Number of lines
 var getPatternName func(slotNum, patternNum int) string
 var setPatternMute func(slotNum, patternNum, muted int32) int32
 var getPatternData func(slotNum, patternNum int) *SunvoxPatternNoteData
+var setPatternEvent func(slotNum, patternNum, track, line, nn, vv, mm, ccee, xxyy int) int32 // USE LOCK/UNLOCK; -1 leaves a field as it is
+var getPatternEvent func(slotNum, patternNum, track, line, column int) int32                 // USE LOCK/UNLOCK; column 0 - 4 is NN, VV, MM, CCEE, XXYY
 
 // Module functions
 
 var getNumberOfModuleSlots func(slotNum int) int32 // Number of module slots (not the number of actual modules)
 var findModule func(slotNum int, name string) int32
 var getModuleFlags func(slotNum, moduleNum int) int32
+var getModuleOutputs func(slotNum, moduleNum int) *int32 // Number of outputs is stored in the module's flags; unused links are -1
 var getModuleName func(slotNum, moduleNum int) string
 var getModuleCtlName func(slotNum, moduleNum, ctrlNum int) string
 var getNumberOfModuleCtls func(slotNum, moduleNum int) int32
@@ -256,11 +270,39 @@ type SunvoxEngine struct {
 	MinorVersion2 int
 
 	// channelIndex int
-	channels map[int]*SunvoxChannel // A map of channel indices to SunvoxChannels, on which one can playback audio.
+	channels     map[int]*SunvoxChannel // A map of channel indices to SunvoxChannels, on which one can playback audio.
+	channelMutex sync.RWMutex
+
+	pollResolution atomic.Int64
+	pollMutex      sync.Mutex
+	pollCancel     context.CancelFunc
+	pollFinished   chan struct{}
+	updateMode     atomic.Int32
+
+	callbackMutex sync.Mutex
+	callbacks     int // Set by the poller while it's calling callbacks (or updating tasks, which can call them)
+
+	tasks          []engineTask
+	tasksAdded     uint64 // How many times a task has been added; guarded by taskMutex
+	taskMutex      sync.Mutex
+	lastTaskUpdate time.Time // Guarded by taskMutex
+
+	latency    atomic.Int64
+	bufferSize int
+
+	tweens *TweenManager
 }
 
-var engine = &SunvoxEngine{
-	channels: map[int]*SunvoxChannel{},
+var engine = newSunvoxEngine()
+
+func newSunvoxEngine() *SunvoxEngine {
+	e := &SunvoxEngine{
+		channels: map[int]*SunvoxChannel{},
+		tweens:   &TweenManager{auto: true},
+	}
+	e.SetPollResolution(0)
+	e.SetLatency(-1)
+	return e
 }
 
 // Engine returns the running Sunvox instance; each process can run only one.
@@ -294,11 +336,13 @@ func (e *SunvoxEngine) Init(libraryPath string, config *InitConfig) error {
 	purego.RegisterLibFunc(&loadFileFromMemory, lib, "sv_load_from_memory")
 	purego.RegisterLibFunc(&setSlotVolume, lib, "sv_volume")
 	purego.RegisterLibFunc(&getCurrentLine, lib, "sv_get_current_line")
+	purego.RegisterLibFunc(&getCurrentLine2, lib, "sv_get_current_line2")
 	purego.RegisterLibFunc(&getCurrentSignalLevel, lib, "sv_get_current_signal_level")
 	purego.RegisterLibFunc(&getSongName, lib, "sv_get_song_name")
 	purego.RegisterLibFunc(&setSongName, lib, "sv_set_song_name")
 	purego.RegisterLibFunc(&getSongBPM, lib, "sv_get_song_bpm")
 	purego.RegisterLibFunc(&getSongTPL, lib, "sv_get_song_tpl")
+	purego.RegisterLibFunc(&getTimeMap, lib, "sv_get_time_map")
 
 	purego.RegisterLibFunc(&rewind, lib, "sv_rewind")
 	purego.RegisterLibFunc(&play, lib, "sv_play")
@@ -318,6 +362,8 @@ func (e *SunvoxEngine) Init(libraryPath string, config *InitConfig) error {
 	purego.RegisterLibFunc(&setEventT, lib, "sv_set_event_t")
 	purego.RegisterLibFunc(&sendEvent, lib, "sv_send_event")
 	purego.RegisterLibFunc(&getPatternData, lib, "sv_get_pattern_data")
+	purego.RegisterLibFunc(&setPatternEvent, lib, "sv_set_pattern_event")
+	purego.RegisterLibFunc(&getPatternEvent, lib, "sv_get_pattern_event")
 
 	purego.RegisterLibFunc(&getNumberOfPatternSlots, lib, "sv_get_number_of_patterns")
 	purego.RegisterLibFunc(&getPatternX, lib, "sv_get_pattern_x")
@@ -333,6 +379,7 @@ func (e *SunvoxEngine) Init(libraryPath string, config *InitConfig) error {
 	purego.RegisterLibFunc(&disconnectModule, lib, "sv_disconnect_module")
 	purego.RegisterLibFunc(&findModule, lib, "sv_find_module")
 	purego.RegisterLibFunc(&getModuleFlags, lib, "sv_get_module_flags")
+	purego.RegisterLibFunc(&getModuleOutputs, lib, "sv_get_module_outputs")
 	purego.RegisterLibFunc(&getModuleName, lib, "sv_get_module_name")
 	purego.RegisterLibFunc(&getModuleCtlName, lib, "sv_get_module_ctl_name")
 	purego.RegisterLibFunc(&getNumberOfModuleCtls, lib, "sv_get_number_of_module_ctls")
@@ -352,13 +399,14 @@ func (e *SunvoxEngine) Init(libraryPath string, config *InitConfig) error {
 		extras = config.ExtraString
 		sampleRate = config.SampleRate
 		flags = config.Flags
+		e.bufferSize = config.bufferSize
 	}
 
 	if sampleRate <= 0 {
 		sampleRate = 44100
 	}
 
-	ver := initEngine(extras, sampleRate, flags)
+	ver := initEngine(extras, sampleRate, 2, flags)
 	if ver < 0 {
 		e.Initialized = false
 		return errors.New("error in initializing:" + strconv.Itoa(int(ver)))
@@ -439,14 +487,28 @@ func (e *SunvoxEngine) InitFromDirectory(libraryBaseDirectoryPath string, config
 // Deinit deinitializes the Sunvox Engine.
 // If for whatever reason that cannot be done, Deinit returns an error.
 //
+// All channels are closed first, which waits for their callbacks to exit, unless callbacks are being called at the time
+// (as when Deinit is called from one); then they aren't waited for, and the rest of the callbacks are skipped.
+//
 // It seems like Deinit() doesn't really work properly at the moment, so it's best not to rely on it.
 func (e *SunvoxEngine) Deinit() error {
+
+	for _, c := range e.channelList() {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+
+	e.stopPolling()
+
 	res := deinitEngine()
 
 	if res != 0 {
 		return errors.New(fmt.Sprintf("error deinitializing sunvox engine; error code %d", res))
 	}
 
+	e.Initialized = false
+
 	return nil
 }
 
@@ -459,6 +521,9 @@ func (e *SunvoxEngine) CreateChannel(id any) (*SunvoxChannel, error) {
 		return nil, errors.New("error: engine has not been initialized")
 	}
 
+	e.channelMutex.Lock()
+	defer e.channelMutex.Unlock()
+
 	available := -1
 
 	// 16 channels max
@@ -501,23 +566,23 @@ const (
 // For example, ChannelAny is for any channel with the ID, ChannelInUse means the channel with the ID has
 // to be in use, and ChannelInUseMaybe means the channel with the ID should be in use if possible;
 // otherwise any channel with the ID would suffice.
+// A channel is in use from when it's played until it's stopped, even if its song has reached the end (unlike IsPlaying()).
 func (e *SunvoxEngine) ChannelByID(id any, inUse ChannelInUseType) *SunvoxChannel {
 
-	for i := 0; i < 16; i++ {
-		c, ok := e.channels[i]
-		if ok && c.ID == id {
+	for _, c := range e.channelList() {
+		if c.ID == id {
 
 			switch inUse {
 			case ChannelInUseMaybe:
 				fallthrough
 			case ChannelInUse:
-				if c.IsPlaying() {
+				if c.playing.Load() {
 					return c
 				}
 			case ChannelNotInUseMaybe:
 				fallthrough
 			case ChannelNotInUse:
-				if !c.IsPlaying() {
+				if !c.playing.Load() {
 					return c
 				}
 			// case ChannelAny:
@@ -538,6 +603,8 @@ func (e *SunvoxEngine) ChannelByID(id any, inUse ChannelInUseType) *SunvoxChanne
 // ChannelByIndex returns the channel with the given index, if it exists / has been created already.
 // If no channel is found, ChannelByID returns nil.
 func (e *SunvoxEngine) ChannelByIndex(index int) *SunvoxChannel {
+	e.channelMutex.RLock()
+	defer e.channelMutex.RUnlock()
 	c, ok := e.channels[index]
 	if ok {
 		return c
@@ -545,10 +612,11 @@ func (e *SunvoxEngine) ChannelByIndex(index int) *SunvoxChannel {
 	return nil
 }
 
-// ForEachChannel loops through each created SunvoxChannel in the engine.
+// ForEachChannel loops through each created SunvoxChannel in the engine. If the function returns false, the function
+// will stop iteration. See Channels() for an iterator.
 func (e *SunvoxEngine) ForEachChannel(forEach func(channel *SunvoxChannel) bool) {
 
-	for _, c := range e.channels {
+	for c := range e.Channels() {
 		if !forEach(c) {
 			break
 		}
@@ -558,7 +626,7 @@ func (e *SunvoxEngine) ForEachChannel(forEach func(channel *SunvoxChannel) bool)
 
 // IsPlayingFilename returns the Channel that has been loaded a project of the given filename.
 func (e *SunvoxEngine) ChannelByFilename(filename string) *SunvoxChannel {
-	for _, c := range e.channels {
+	for _, c := range e.channelList() {
 		if c.ProjectFilename() == filename {
 			return c
 		}
@@ -566,6 +634,19 @@ func (e *SunvoxEngine) ChannelByFilename(filename string) *SunvoxChannel {
 	return nil
 }
 
+// channelList returns a copy of the engine's created channels, ordered by index.
+func (e *SunvoxEngine) channelList() []*SunvoxChannel {
+	e.channelMutex.RLock()
+	defer e.channelMutex.RUnlock()
+	channels := make([]*SunvoxChannel, 0, len(e.channels))
+	for i := 0; i < 16; i++ {
+		if c, ok := e.channels[i]; ok {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
 // SampleRate returns the sample rate of the engine.
 func (e *SunvoxEngine) SampleRate() (int, error) {
 	sampleRate := getSampleRate()
@@ -591,21 +672,84 @@ type SunvoxChannel struct {
 	byteData []byte
 	Index    int
 	ID       any
-	playing  bool
+	playing  atomic.Bool
 	filename string
 
-	hasCustomLoop   bool
-	customLoopStart int
-	customLoopEnd   int
-
-	goroutineCancels map[string]chan bool
+	regionMutex     sync.Mutex
+	regions         map[string]LoopRegion
+	activeRegion    string
+	pendingRegion   string
+	switchingRegion bool
+	regionJump      bool      // Whether a jump back to a region's start has been scheduled
+	regionJumpTo    string    // The region the scheduled jump goes to
+	regionJumpTime  time.Time // When the scheduled jump is heard
+	regionPollTime  time.Time // When the loop region was last updated by the poller
+
+	markerMutex  sync.Mutex
+	markers      []Marker
+	markerPrefix string
+
+	timeSignatureMutex sync.Mutex
+	timeSignature      TimeSignature
+	transitionMutex    sync.Mutex
+	transitions        []*transition
+
+	noteLookAhead atomic.Int64
+	noteMutex     sync.Mutex
+	noteVersion   uint64                // Incremented whenever the cached notes are cleared, so notes read before then aren't cached
+	patternNotes  map[int]*patternNotes // The cached notes of each pattern, by pattern index
+
+	eventMutex        sync.Mutex // Held while sending events, so timestamped events from an EventScheduler don't mix with others
+	eventTimestampSet bool       // The timestamp set through SetEventTimestamp(), which is restored after sending timestamped events
+	eventTimestamp    uint32
+
+	voiceMutex     sync.Mutex
+	voices         [EventTrackCount]*Voice
+	reservedTracks [EventTrackCount]bool // Event tracks that Instruments don't play voices on (as StepTracks use them)
+
+	clockMutex    sync.Mutex
+	clockPosition float64
+	clockTime     time.Time
+	lineTPL       []int
+	lineTicks     []int
+
+	patternCache cache
+
+	ctx                context.Context
+	cancel             context.CancelFunc
+	subscriptions      sync.WaitGroup
+	subscriptionMutex  sync.Mutex
+	namedSubscriptions map[string]*Subscription
+	poller             eventPoller
+	seekCount          atomic.Uint64 // Incremented whenever the channel is seeked, so the poller doesn't mistake seeking for looping
+	playCount          atomic.Uint64 // Incremented whenever playback is started
+
+	queue commandQueue
+
+	pauseMutex sync.Mutex
+	pauseDepth int
+	userPaused atomic.Bool
+
+	loopMutex          sync.Mutex
+	loopCount          int
+	loopLimit          int
+	loopThen           func()
+	loopRestoreLooping bool
+
+	journalMutex   sync.Mutex
+	journal        editJournal
+	journalEnabled atomic.Bool
 }
 
 func newSunvoxChannel(id any, index int) *SunvoxChannel {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &SunvoxChannel{
-		ID:               id,
-		Index:            index,
-		goroutineCancels: map[string]chan bool{},
+		ID:                 id,
+		Index:              index,
+		ctx:                ctx,
+		cancel:             cancel,
+		namedSubscriptions: map[string]*Subscription{},
+		regions:            map[string]LoopRegion{},
 	}
 }
 
@@ -634,6 +778,11 @@ func (s *SunvoxChannel) LoadFileFromBytes(data []byte) error {
 	}
 	s.byteData = data
 	s.filename = ""
+	s.patternCache.Clear()
+	s.invalidateMarkers()
+	s.invalidateNotes(-1)
+	s.invalidateTimeMap()
+	s.ClearJournal()
 	return nil
 }
 
@@ -696,12 +845,17 @@ func (s *SunvoxChannel) ProjectName() string {
 // SetProjectName sets the name for the project loaded in the channel.
 // If there is an issue getting the song name, the function will return an error.
 func (s *SunvoxChannel) SetProjectName(name string) error {
+	previous := s.ProjectName()
 	res := setSongName(s.Index, name)
 
 	if res != 0 {
 		return errors.New(fmt.Sprintf("error setting the project name for the project loaded in channel index %d; error code %d", s.Index, res))
 	}
 
+	if previous != name {
+		s.recordEdit("Rename project", projectNameEdit(s, previous))
+	}
+
 	return nil
 
 }
@@ -734,20 +888,32 @@ func (s *SunvoxChannel) Volume() (float32, error) {
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
+//
+// Note that functions that initiate or modify playback on a playing SunvoxChannel will be slow (on the order of ~50-100ms).
+// If you do not need it immediately, it might be best to queue playback using QueuePlayFromBeginning().
 func (s *SunvoxChannel) PlayFromBeginning() error {
 
 	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
+	s.pauseEngine()
+	defer s.resumeEngine()
 
+	return s.doPlayFromBeginning()
+
+}
+
+func (s *SunvoxChannel) doPlayFromBeginning() error {
 	res := playFromBeginning(s.Index)
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error playing SunvoxChannel index %d; error code %d", s.Index, res))
 	}
-	s.playing = true
-
+	// Playback starts from the beginning of a confining loop region instead, if one is active
+	if start, ok := s.confinedRegionStart(); ok {
+		rewind(s.Index, start)
+	}
+	s.playing.Store(true)
+	s.playCount.Add(1)
+	engine.startPolling()
 	return nil
-
 }
 
 // Play plays the song contained within the SunvoxChannel from wherever the playhead currently is.
@@ -756,18 +922,26 @@ func (s *SunvoxChannel) PlayFromBeginning() error {
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
+//
+// Note that functions that initiate or modify playback on a playing SunvoxChannel will be slow (on the order of ~50-100ms).
+// If you do not need it immediately, it might be best to queue playback using QueuePlay().
 func (s *SunvoxChannel) Play() error {
 
 	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
+	s.pauseEngine()
+	defer s.resumeEngine()
 
+	return s.doPlay()
+}
+
+func (s *SunvoxChannel) doPlay() error {
 	res := play(s.Index)
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error playing SunvoxChannel index %d; error code %d", s.Index, res))
 	}
-	s.playing = true
-
+	s.playing.Store(true)
+	s.playCount.Add(1)
+	engine.startPolling()
 	return nil
 }
 
@@ -776,12 +950,20 @@ func (s *SunvoxChannel) Play() error {
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
+//
+// Note that functions that initiate or modify playback on a playing SunvoxChannel will be slow (on the order of ~50-100ms).
+// If you do not need it immediately, it might be best to queue playback using QueueSeek().
 func (s *SunvoxChannel) Seek(lineNum int) error {
 
 	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
+	s.pauseEngine()
+	defer s.resumeEngine()
+
+	return s.doSeek(lineNum)
+}
 
+func (s *SunvoxChannel) doSeek(lineNum int) error {
+	s.seekCount.Add(1)
 	res := rewind(s.Index, lineNum)
 
 	if res != 0 {
@@ -803,9 +985,13 @@ func (s *SunvoxChannel) Seek(lineNum int) error {
 func (s *SunvoxChannel) Stop() error {
 
 	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
+	s.pauseEngine()
+	defer s.resumeEngine()
+
+	return s.doStop()
+}
 
+func (s *SunvoxChannel) doStop() error {
 	if !s.IsValid() {
 		return nil
 	}
@@ -813,254 +999,151 @@ func (s *SunvoxChannel) Stop() error {
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error playing SunvoxChannel index %d; error code %d", s.Index, res))
 	}
-	s.playing = false
+	s.playing.Store(false)
 
-	return nil
+	s.loopMutex.Lock()
+	defer s.loopMutex.Unlock()
+	return s.clearLoopLimit()
 }
 
-const customLoopLineAmount = 99999999
+// customLoopRegionName is the name of the LoopRegion used for SetCustomLoop().
+const customLoopRegionName = "sunvoxgo.customLoop"
 
-// SetCustomLoop loops a stretch of patterns between two line numbers by isolating them.
-// This is done by moving all other patterns to the left, and aligning the
-// patterns between the ranges specified to start at line 0 (so normal playback with looping enabled
-// goes from line 0 to the end of the pattern range specified).
+// SetCustomLoop loops playback between two line numbers, confining playback to them. This is a shortcut for adding
+// a LoopRegion with Confine set to true and making it the active one; see SetActiveLoopRegion() for more information.
+// The project itself (i.e. the patterns' positions) is left unchanged.
+//
+// Playing the song from the beginning while a custom loop is set starts playback from startX.
 //
-// Be sure to restart playback from the beginning / line 0 after calling SetCustomLoop, as Sunvox's engine
-// finds the bounds of the current song for looping / ending purposes when playback is initiated.
+// To set a custom loop temporarily, set it within an edit step and cancel the step once it's no longer needed (see
+// SunvoxChannel.CancelEditStep()); this restores whatever loop regions were set beforehand.
 func (s *SunvoxChannel) SetCustomLoop(startX, endX int) {
 
-	if startX == s.customLoopStart && endX == s.customLoopEnd {
+	s.BeginEditStep("Set custom loop")
+	defer s.EndEditStep()
+
+	if err := s.SetLoopRegion(LoopRegion{Name: customLoopRegionName, Start: startX, End: endX, Confine: true}); err != nil {
 		return
 	}
 
-	s.ResetCustomLoop()
-
-	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
-
-	leastX := math.MaxInt
-
-	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
-
-		lc, err := pattern.LineCount()
-		if err != nil {
-			return true
-		}
-
-		if pattern.CustomLooplessX() >= startX && pattern.CustomLooplessX()+lc <= endX {
-			if pattern.CustomLooplessX() < leastX {
-				leastX = pattern.CustomLooplessX()
-			}
-		} else if pattern.X() > -100_000 {
-			pattern.Move(-customLoopLineAmount, 0)
-		}
-		return true
-
-	})
-
-	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
-		if pattern.X() > 0 {
-			pattern.Move(-leastX, 0)
-		}
-		return true
-	})
-
-	s.hasCustomLoop = true
-	s.customLoopStart = leastX
-	s.customLoopEnd = endX - leastX
+	s.SetActiveLoopRegion(customLoopRegionName)
 
 }
 
 // CustomLoopStart returns the start of the custom loop, if one is set.
-// If one is not set, then it returns -1.
+// If one is not set, then it returns 0.
 func (s *SunvoxChannel) CustomLoopStart() int {
-	if s.hasCustomLoop {
-		return s.customLoopStart
+	if region, ok := s.LoopRegion(customLoopRegionName); ok {
+		return region.Start
 	}
 	return 0
 }
 
 // CustomLoopEnd returns the end of the custom loop, if one is set.
-// If one is not set, then it returns -1.
+// If one is not set, then it returns 0.
 func (s *SunvoxChannel) CustomLoopEnd() int {
-	if s.hasCustomLoop {
-		return s.customLoopEnd
+	if region, ok := s.LoopRegion(customLoopRegionName); ok {
+		return region.End
 	}
 	return 0
 }
 
-// ResetCustomLoop resets any custom loop by moving Patterns back to their original locations.
+// ResetCustomLoop removes any custom loop, so playback continues normally from wherever the playhead is.
 func (s *SunvoxChannel) ResetCustomLoop() {
-
-	if !s.hasCustomLoop {
-		return
-	}
-
-	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
-
-	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
-
-		if pattern.X() < 0 {
-			pattern.Move(customLoopLineAmount, 0)
-		} else {
-			pattern.Move(s.customLoopStart, 0)
-		}
-
-		return true
-	})
-
-	s.customLoopStart = 0
-	s.customLoopEnd = 0
-	s.hasCustomLoop = false
-
+	s.BeginEditStep("Reset custom loop")
+	defer s.EndEditStep()
+	s.RemoveLoopRegion(customLoopRegionName)
 }
 
 // HasCustomLoop returns if a custom loop is set.
 func (s *SunvoxChannel) HasCustomLoop() bool {
-	return s.hasCustomLoop
+	region, ok := s.ActiveLoopRegion()
+	return ok && region.Name == customLoopRegionName
 }
 
-// SetOnCurrentLineChange sets a callback to be run on another goroutine that signals when the line changes.
+// OnCurrentLineChange adds a callback to be run on another goroutine that signals when the line changes.
 // (Note that Sunvox's audio engine is going to be ahead of the callback by some time.
 // Also note that when playing a song from beginning, the playhead goes to -1 momentarily.)
 //
+// ctx can be used to cancel the callback; if it's nil, the callback runs until it's canceled through the returned Subscription
+// or the channel closes.
 // pollResolution is the resolution of the polling for the callback / amount of time slept between polls.
-// If less than or equal to 0, it will be the default (10ms / 100 times per second).
-// onLineChange is the callback to be called; if it returns false, the goroutine exits.
+// If greater than 0, the engine's poll resolution is lowered to it if it's longer (see SunvoxEngine.SetPollResolution()).
+// onLineChange is the callback to be called; if it returns false, the callback is canceled.
 //
-// The goroutine will exit if the Channel closes or another callback is set.
-// Setting onLineChange to nil will cancel any currently running callback.
-func (s *SunvoxChannel) SetOnCurrentLineChange(pollResolution time.Duration, onLinechange func(line int) bool) {
-
-	// Attempt to cancel a running goroutine if one has been set for this callback
-	s.cancelRunningGoroutine("SetOnCurrentLineChange")
-
-	if onLinechange == nil {
-		return
-	}
+// Any number of callbacks can be added at the same time. This is a shortcut for Subscribe() with EventLineChanged.
+func (s *SunvoxChannel) OnCurrentLineChange(ctx context.Context, pollResolution time.Duration, onLineChange func(line int) bool) *Subscription {
 
-	if pollResolution <= 0 {
-		pollResolution = time.Millisecond * 10
+	if pollResolution > 0 && pollResolution < engine.PollResolution() {
+		engine.SetPollResolution(pollResolution)
 	}
 
-	cancel := make(chan bool, 1)
-
-	go func(cancel chan bool) {
-		line := -999999999
-		for {
+	return s.Subscribe(ctx, func(event Event) bool {
+		return onLineChange(event.Line)
+	}, EventLineChanged)
 
-			select {
-			case <-cancel:
-				return
-			default:
+}
 
-				l := s.CurrentLine()
+// SetOnCurrentLineChange sets a callback to be run on another goroutine that signals when the line changes.
+// See OnCurrentLineChange() for more information.
+//
+// Unlike OnCurrentLineChange(), only one callback can be set through SetOnCurrentLineChange at a time; setting another
+// callback cancels the previous one. Setting onLineChange to nil will cancel any currently running callback.
+func (s *SunvoxChannel) SetOnCurrentLineChange(pollResolution time.Duration, onLineChange func(line int) bool) *Subscription {
 
-				if l != line {
-					if onLinechange != nil {
-						if !onLinechange(l) {
-							return
-						}
-					}
-					line = l
-				}
-			}
+	if onLineChange == nil {
+		s.replaceSubscription("SetOnCurrentLineChange", nil)
+		return nil
+	}
 
-			time.Sleep(pollResolution)
+	sub := s.OnCurrentLineChange(nil, pollResolution, onLineChange)
+	s.replaceSubscription("SetOnCurrentLineChange", sub)
+	return sub
 
-		}
-	}(cancel)
-	s.goroutineCancels["SetOnCurrentLineChange"] = cancel
 }
 
-// SetOnPatternTouch sets a callback to be run on another goroutine when patterns are touched by the playhead during playback.
+// OnPatternTouch adds a callback to be run on another goroutine when patterns are touched by the playhead during playback.
 // (Note that Sunvox's audio engine is going to be ahead of the callback by some time.
 // Also note that when playing a song from beginning, the playhead goes to -1 momentarily,
 // so there will be a false execution of the callback on first run.)
 //
+// ctx can be used to cancel the callback; if it's nil, the callback runs until it's canceled through the returned Subscription
+// or the channel closes.
 // pollResolution is the resolution of the polling for the callback / amount of time slept between polls.
-// If less than or equal to 0, it will be the default (10ms / 100 times per second).
+// If greater than 0, the engine's poll resolution is lowered to it if it's longer (see SunvoxEngine.SetPollResolution()).
 // onPatternTouch is the callback to be called with the SunvoxPattern that was touched. The callback must return a boolean value;
-// if it returns false, the goroutine exits.
+// if it returns false, the callback is canceled.
 // justStarted indicates if the pattern is just starting to be played. If false, the pattern is just finishing being played.
 //
-// The goroutine will exit if the Channel closes or another callback is set.
-// Setting onPatternTouch to nil will cancel any currently running callback.
-func (s *SunvoxChannel) SetOnPatternTouch(pollResolution time.Duration, onPatternTouch func(p *SunvoxPattern, justStarted bool) bool) {
-
-	// Attempt to cancel a running goroutine if one has been set for this callback
-	s.cancelRunningGoroutine("SetOnPatternTouch")
+// Any number of callbacks can be added at the same time. This is a shortcut for Subscribe() with EventPatternEntered and
+// EventPatternExited.
+func (s *SunvoxChannel) OnPatternTouch(ctx context.Context, pollResolution time.Duration, onPatternTouch func(p *SunvoxPattern, justStarted bool) bool) *Subscription {
 
-	if onPatternTouch == nil {
-		return
+	if pollResolution > 0 && pollResolution < engine.PollResolution() {
+		engine.SetPollResolution(pollResolution)
 	}
 
-	if pollResolution <= 0 {
-		pollResolution = time.Millisecond * 10
-	}
-
-	cancel := make(chan bool, 1)
-
-	go func(cancel chan bool) {
-
-		touchingPatterns := map[int]struct{}{}
-		wasTouchingPatterns := map[int]struct{}{}
-
-		for {
-
-			select {
-			case <-cancel:
-				return
-			default:
-
-				l := s.CurrentLine()
-				s.ForEachPattern(func(pattern *SunvoxPattern) bool {
-					lc, _ := pattern.LineCount()
-
-					if l >= pattern.X() && l <= pattern.X()+lc {
-						touchingPatterns[pattern.Index] = struct{}{}
-					}
-					return true
-				})
-
-				for patternIndex := range touchingPatterns {
-					_, wasTouching := wasTouchingPatterns[patternIndex]
-					if !wasTouching {
-						if !onPatternTouch(s.PatternByIndex(patternIndex), true) {
-							return
-						}
-					}
-				}
-
-				for patternIndex := range wasTouchingPatterns {
-					_, nowTouching := touchingPatterns[patternIndex]
-					if !nowTouching {
-						if !onPatternTouch(s.PatternByIndex(patternIndex), false) {
-							return
-						}
-					}
-				}
-
-				clear(wasTouchingPatterns)
-
-				for k, v := range touchingPatterns {
-					wasTouchingPatterns[k] = v
-				}
-
-				clear(touchingPatterns)
-
-			}
+	return s.Subscribe(ctx, func(event Event) bool {
+		return onPatternTouch(event.Pattern, event.Type == EventPatternEntered)
+	}, EventPatternEntered, EventPatternExited)
 
-			time.Sleep(pollResolution)
+}
 
-		}
+// SetOnPatternTouch sets a callback to be run on another goroutine when patterns are touched by the playhead during playback.
+// See OnPatternTouch() for more information.
+//
+// Unlike OnPatternTouch(), only one callback can be set through SetOnPatternTouch at a time; setting another
+// callback cancels the previous one. Setting onPatternTouch to nil will cancel any currently running callback.
+func (s *SunvoxChannel) SetOnPatternTouch(pollResolution time.Duration, onPatternTouch func(p *SunvoxPattern, justStarted bool) bool) *Subscription {
 
-	}(cancel)
+	if onPatternTouch == nil {
+		s.replaceSubscription("SetOnPatternTouch", nil)
+		return nil
+	}
 
-	s.goroutineCancels["SetOnPatternTouch"] = cancel
+	sub := s.OnPatternTouch(nil, pollResolution, onPatternTouch)
+	s.replaceSubscription("SetOnPatternTouch", sub)
+	return sub
 
 }
 
@@ -1077,6 +1160,12 @@ func (s *SunvoxChannel) CurrentLine() int {
 	return int(getCurrentLine(s.Index))
 }
 
+// CurrentPosition returns the current line number of the playhead along with how far into the line it is (so 12.5 is
+// halfway through line 12), in steps of 1/32 of a line.
+func (s *SunvoxChannel) CurrentPosition() float64 {
+	return float64(getCurrentLine2(s.Index)) / 32
+}
+
 // LengthInFrames returns the length of the project in frames.
 func (s *SunvoxChannel) LengthInFrames() int {
 	return int(getLengthFrames(s.Index))
@@ -1105,15 +1194,19 @@ func (s *SunvoxChannel) Length() (time.Duration, error) {
 //
 // This does not pause the playback state of the project
 // (i.e. if it was playing a song, it still is, even if the audio engine is paused, regardless of if a song is playing.).
+// While the audio engine is paused through PauseAudioEngine(), State() returns StatePaused for a playing song.
 //
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (s *SunvoxChannel) PauseAudioEngine() error {
+	s.pauseMutex.Lock()
+	defer s.pauseMutex.Unlock()
 	res := pause(s.Index)
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error playing SunvoxChannel index %d; error code %d", s.Index, res))
 	}
+	s.userPaused.Store(true)
 	return nil
 }
 
@@ -1124,6 +1217,13 @@ func (s *SunvoxChannel) PauseAudioEngine() error {
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (s *SunvoxChannel) ResumeAudioEngine() error {
+	s.pauseMutex.Lock()
+	defer s.pauseMutex.Unlock()
+	s.userPaused.Store(false)
+	// Internal edits resume the audio engine themselves when they're done
+	if s.pauseDepth > 0 {
+		return nil
+	}
 	res := resume(s.Index)
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error playing SunvoxChannel index %d; error code %d", s.Index, res))
@@ -1131,6 +1231,28 @@ func (s *SunvoxChannel) ResumeAudioEngine() error {
 	return nil
 }
 
+// pauseEngine pauses the audio engine for internal edits. Unlike PauseAudioEngine(), calls can be nested; the engine
+// is only paused by the outermost call and resumed by the matching resumeEngine() call (unless it was paused
+// through PauseAudioEngine(), in which case it stays paused).
+func (s *SunvoxChannel) pauseEngine() {
+	s.pauseMutex.Lock()
+	defer s.pauseMutex.Unlock()
+	if s.pauseDepth == 0 {
+		pause(s.Index)
+	}
+	s.pauseDepth++
+}
+
+// resumeEngine resumes the audio engine after an internal edit started with pauseEngine().
+func (s *SunvoxChannel) resumeEngine() {
+	s.pauseMutex.Lock()
+	defer s.pauseMutex.Unlock()
+	s.pauseDepth--
+	if s.pauseDepth == 0 && !s.userPaused.Load() {
+		resume(s.Index)
+	}
+}
+
 // IsLooping returns if the SunvoxChannel is set to loop audio playback (which is the default).
 func (s *SunvoxChannel) IsLooping() bool {
 	return getAutostop(s.Index) == 0
@@ -1147,8 +1269,13 @@ func (s *SunvoxChannel) SetLooping(loop bool) error {
 		return nil
 	}
 
-	s.PauseAudioEngine()
-	defer s.ResumeAudioEngine()
+	s.pauseEngine()
+	defer s.resumeEngine()
+
+	return s.doSetLooping(loop)
+}
+
+func (s *SunvoxChannel) doSetLooping(loop bool) error {
 
 	st := 1
 	if loop {
@@ -1164,10 +1291,51 @@ func (s *SunvoxChannel) SetLooping(loop bool) error {
 	return nil
 }
 
-// Returns if the channel is currently playing back audio.
-// This will return true even if a one-shot / non-looped song is stopped at the end of the song.
+// Returns if the channel is currently playing back audio (i.e. its State() is StatePlaying).
+// This returns false once a one-shot / non-looped song reaches the end of the song.
 func (s *SunvoxChannel) IsPlaying() bool {
-	return s.playing
+	return s.State() == StatePlaying
+}
+
+// PlaybackState indicates the playback state of a SunvoxChannel.
+type PlaybackState int
+
+const (
+	StateStopped PlaybackState = iota // Nothing is playing, either because playback hasn't been started or because it was stopped through Stop()
+	StatePlaying                      // A song is playing
+	StatePaused                       // A song is playing, but the audio engine has been paused through PauseAudioEngine()
+	StateEnded                        // A song that doesn't loop was playing and reached its end
+)
+
+// String returns the name of the PlaybackState.
+func (p PlaybackState) String() string {
+	switch p {
+	case StateStopped:
+		return "Stopped"
+	case StatePlaying:
+		return "Playing"
+	case StatePaused:
+		return "Paused"
+	case StateEnded:
+		return "Ended"
+	}
+	return "Unknown"
+}
+
+// State returns the playback state of the SunvoxChannel. This combines the commands given to the channel (e.g. Play(),
+// Stop(), PauseAudioEngine()) with the state reported by Sunvox, so a song that doesn't loop is reported as StateEnded
+// once it has finished playing.
+func (s *SunvoxChannel) State() PlaybackState {
+	if !s.playing.Load() {
+		return StateStopped
+	}
+	if s.IsAtEndOfSong() {
+		return StateEnded
+	}
+	if s.userPaused.Load() {
+		return StatePaused
+	}
+	return StatePlaying
 }
 
 // Returns if the channel is at the end of the song (only if the song does not loop).
@@ -1176,6 +1344,8 @@ func (s *SunvoxChannel) IsAtEndOfSong() bool {
 }
 
 // PatternCount returns the number of patterns in the channel, and an error if it was impossible to determine.
+// Note that as the slots of removed patterns are left empty, pattern indices can go past the pattern count; use
+// Patterns() or ForEachPattern() to iterate through the patterns.
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
@@ -1209,16 +1379,12 @@ func (s *SunvoxChannel) PatternByName(name string) *SunvoxPattern {
 	return nil
 }
 
-// PatternByIndex returnss the specified numeric patternIndex argument.
-// If patternIndex is outside of the range of patterns in the song, PatternByIndex will return nil.
+// PatternByIndex returns the pattern with the specified numeric patternIndex argument.
+// If no pattern exists with the given index (including if the pattern in that slot was removed), PatternByIndex will
+// return nil.
 func (s *SunvoxChannel) PatternByIndex(patternIndex int) *SunvoxPattern {
 
-	patternCount, err := s.PatternCount()
-	if err != nil {
-		return nil
-	}
-
-	if patternIndex < 0 || patternIndex > patternCount {
+	if patternIndex < 0 || patternIndex >= int(getNumberOfPatternSlots(s.Index)) {
 		return nil
 	}
 
@@ -1235,16 +1401,9 @@ func (s *SunvoxChannel) PatternByIndex(patternIndex int) *SunvoxPattern {
 
 // ForEachPattern iterates through all patterns contained in the SunvoxChannel and executes the provided forEach
 // function on each one. If the function returns false, the function stops iterating through the pattern set.
+// See Patterns() for an iterator.
 func (s *SunvoxChannel) ForEachPattern(forEach func(pattern *SunvoxPattern) bool) {
-	count, err := s.PatternCount()
-	if err != nil {
-		return
-	}
-	for i := 0; i < count; i++ {
-		p := &SunvoxPattern{
-			Channel: s,
-			Index:   i,
-		}
+	for p := range s.Patterns() {
 		if !forEach(p) {
 			break
 		}
@@ -1278,56 +1437,103 @@ func (s *SunvoxChannel) Unlock() error {
 }
 
 // Close closes the channel and removes it from playback.
+// Any functions still waiting in the channel's queue are canceled (and their Futures resolve with ErrorChannelClosed), and
+// all callbacks are canceled.
+// Close waits for the channel's callbacks to finish, unless callbacks are being called at the time (as when Close is
+// called from one); then they aren't waited for, and the rest of the channel's callbacks are skipped. Close shouldn't be called from a queued function, as it waits for the channel's queue
+// worker goroutine to exit.
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (s *SunvoxChannel) Close() error {
+
+	// Stop the queue worker and callbacks first so they don't touch the slot after it has been closed
+	s.stopQueue()
+	s.cancelSubscriptions()
+	s.closeTransitions()
+
 	res := closeSlot(s.Index)
 	if res != 0 {
 		return errors.New(fmt.Sprintf("error closing channel %d", s.Index))
 	}
+	engine.channelMutex.Lock()
 	delete(engine.channels, s.Index)
-
-	// Cancel all running callback goroutines
-	s.cancelRunningGoroutine("")
+	engine.channelMutex.Unlock()
 
 	return nil
 }
 
-func (s *SunvoxChannel) cancelRunningGoroutine(cancelName string) {
-	if cancelName == "" {
-		for _, c := range s.goroutineCancels {
-			c <- true
-		}
-		clear(s.goroutineCancels)
-	} else {
-		if c, ok := s.goroutineCancels[cancelName]; ok {
-			c <- true
-		}
-		delete(s.goroutineCancels, cancelName)
+// Reopen stops playback and closes and reopens the SunvoxChannel, clearing the project loaded into it so that another
+// one can be loaded (as loading fails on a channel that has begun playback). The SunvoxChannel keeps its index and ID,
+// its subscriptions and its settings (like its time signature and marker prefix), but its loop regions and loop limit
+// are removed and any pending transitions are canceled.
+func (s *SunvoxChannel) Reopen() error {
+
+	s.Stop()
+	s.CancelTransitions()
+
+	if res := closeSlot(s.Index); res != 0 {
+		return errors.New(fmt.Sprintf("error closing channel %d for reopening; error code %d", s.Index, res))
+	}
+
+	if res := openSlot(s.Index); res != 0 {
+		return errors.New(fmt.Sprintf("error reopening channel %d; error code %d", s.Index, res))
 	}
+
+	s.regionMutex.Lock()
+	clear(s.regions)
+	s.activeRegion = ""
+	s.pendingRegion = ""
+	s.switchingRegion = false
+	s.regionJump = false
+	s.regionMutex.Unlock()
+
+	s.ClearJournal()
+
+	s.byteData = nil
+	s.filename = ""
+	s.patternCache.Clear()
+	s.invalidateMarkers()
+	s.invalidateNotes(-1)
+	s.invalidateTimeMap()
+	s.clearVoices()
+
+	return nil
+
 }
 
 // SetEventTimestamps sets the timestamp for sending events. The final timestamps is when the event
 // can be heard from the speakers. If setTimestamp is false, then the event will be automatically set to
 // the current time. Otherwise, the resulting time is the timestamp + sound latency * 2 (with timestamp
 // being retrieved from GetTicks()).
+// To send events at precise times without handling timestamps manually, see EventScheduler.
 // If the SunvoxChannel is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (s *SunvoxChannel) SetEventTimestamp(setTimestamp bool, timestamp uint32) error {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
+	s.eventTimestampSet = setTimestamp
+	s.eventTimestamp = timestamp
+	if res := s.restoreEventTimestamp(); res < 0 {
+		return errors.New(fmt.Sprintf("error setting event timestamp for channel %d", s.Index))
+	}
+	return nil
+}
+
+// restoreEventTimestamp sets Sunvox's event timestamp back to the one set through SetEventTimestamp(), after sending
+// events with timestamps of their own. eventMutex must be held.
+func (s *SunvoxChannel) restoreEventTimestamp() int32 {
 	set := 0
-	if setTimestamp {
+	if s.eventTimestampSet {
 		set = 1
 	}
-	res := setEventT(s.Index, set, timestamp)
-	if res < 0 {
-		return errors.New(fmt.Sprintf("error sending event to channel %d", s.Index))
-	}
-	return nil
+	return setEventT(s.Index, set, s.eventTimestamp)
 }
 
 func (s *SunvoxChannel) SendEvent(trackNum, note, velocity, module, ctrlEffect, parameterValue int) error {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
 	res := sendEvent(s.Index, trackNum, note, velocity, module, ctrlEffect, parameterValue)
 	if res < 0 {
 		return errors.New(fmt.Sprintf("error sending event to channel %d", s.Index))
@@ -1351,16 +1557,12 @@ func (p *SunvoxPattern) IsValid() bool {
 // If the SunvoxPattern is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
+//
+// To move many patterns at once, use SetPatternXY() in a SunvoxChannel.Batch() instead.
 func (p *SunvoxPattern) SetXY(x, y int) error {
-	p.Channel.PauseAudioEngine()
-	defer p.Channel.ResumeAudioEngine()
-	p.Channel.Lock()
-	res := setPatternXY(p.Channel.Index, p.Index, x, y)
-	if res != 0 {
-		return errors.New(fmt.Sprintf("error setting pattern %d x, y to %d, %d in channel %d; error code %d", p.Index, x, y, p.Channel.Index, res))
-	}
-	p.Channel.Unlock()
-	return nil
+	return p.Channel.Batch(func(tx *ChannelTx) error {
+		return tx.SetPatternXY(p, x, y)
+	})
 }
 
 // Move moves the pattern by the dx (with dx being in lines) and dy values specified.
@@ -1390,32 +1592,18 @@ func (p *SunvoxPattern) X2() int {
 
 // CustomLooplessX returns the Line number (x-coordinate) of the pattern in Sunvox as if there was no
 // custom loop set.
+//
+// Deprecated: Custom loops no longer move patterns, so this is the same as X().
 func (p *SunvoxPattern) CustomLooplessX() int {
-	x := p.X()
-	if p.Channel.hasCustomLoop {
-		// Less than -100K line number, we're gonna assume it must be due to a custom loop, haha
-		if x < -100_000 {
-			x += customLoopLineAmount
-		} else {
-			x += p.Channel.customLoopStart
-		}
-	}
-	return x
+	return p.X()
 }
 
 // CustomLooplessX2 returns the Line number (x-coordinate) of the end of the pattern in Sunvox as if there was no
 // custom loop set.
+//
+// Deprecated: Custom loops no longer move patterns, so this is the same as X2().
 func (p *SunvoxPattern) CustomLooplessX2() int {
-	x := p.X2()
-	if p.Channel.hasCustomLoop {
-		// Less than -100K line number, we're gonna assume it must be due to a custom loop, haha
-		if x < -100_000 {
-			x += customLoopLineAmount
-		} else {
-			x += p.Channel.customLoopStart
-		}
-	}
-	return x
+	return p.X2()
 }
 
 // Name returns the name of the given Pattern.
@@ -1429,29 +1617,47 @@ func (p *SunvoxPattern) Name() string {
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (p *SunvoxPattern) SetMute(muted bool) (bool, error) {
-	m := 0
-	if muted {
-		m = 1
-	}
 
 	if err := p.Channel.Lock(); err != nil {
 		return false, err
 	}
+	defer p.Channel.Unlock()
 
-	res := setPatternMute(int32(p.Channel.Index), int32(p.Index), int32(m))
+	return p.setMute(muted)
+}
 
-	if err := p.Channel.Unlock(); err != nil {
-		return false, err
+func (p *SunvoxPattern) setMute(muted bool) (bool, error) {
+	m := 0
+	if muted {
+		m = 1
 	}
 
+	res := setPatternMute(int32(p.Channel.Index), int32(p.Index), int32(m))
+
 	if res < 0 {
 		return false, errors.New(fmt.Sprintf("error muting pattern %d in channel %d; error code %d", p.Index, p.Channel.Index, res))
 	}
 
-	if res == 1 {
-		return true, nil
+	previous := res == 1
+
+	if previous != muted {
+		p.Channel.invalidateNotes(p.Index)
+		p.Channel.recordEdit("Mute pattern", patternMuteEdit(p, previous))
 	}
-	return false, nil
+
+	return previous, nil
+}
+
+// IsMuted returns whether the pattern is muted.
+func (p *SunvoxPattern) IsMuted() bool {
+
+	if err := p.Channel.Lock(); err != nil {
+		return false
+	}
+	defer p.Channel.Unlock()
+
+	// A negative value leaves the mute state as is, returning it
+	return setPatternMute(int32(p.Channel.Index), int32(p.Index), -1) == 1
 }
 
 var cachedPatternData = map[int]map[string]any{}
@@ -1462,19 +1668,19 @@ var cachedPatternData = map[int]map[string]any{}
 // will print exactly what the error might be).
 func (p *SunvoxPattern) LineCount() (int, error) {
 
-	if v := patternCache.Get(p.Index, "LineCount"); v != nil {
+	if v := p.Channel.patternCache.Get(p.Index, "LineCount"); v != nil {
 		return v.(int), nil
 	}
 
-	p.Channel.PauseAudioEngine()
-	defer p.Channel.ResumeAudioEngine()
+	p.Channel.pauseEngine()
+	defer p.Channel.resumeEngine()
 
 	res := getPatternLineCount(p.Channel.Index, p.Index)
 	if res < 0 {
 		return int(res), errors.New(fmt.Sprintf("error getting pattern line count from channel %d and pattern %d", p.Channel.Index, p.Index))
 	}
 
-	patternCache.Set(p.Index, "LineCount", int(res))
+	p.Channel.patternCache.Set(p.Index, "LineCount", int(res))
 
 	return int(res), nil
 }
@@ -1493,11 +1699,13 @@ func (p *SunvoxPattern) TrackCount() (int, error) {
 }
 
 // Data returns the data from the pattern for reading and modification.
+// Note that the data is a view of the pattern's memory within Sunvox itself, so it's only valid until the pattern is
+// resized or removed, and writing to it while the song is playing races with playback unless the channel is locked.
+// For safer access, use Snapshot() and Commit(), or Event() and SetEvent().
 // If the SunvoxPattern is unable to execute the function for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (p *SunvoxPattern) Data() (*SunvoxPatternData, error) {
-	addr := getPatternData(p.Channel.Index, p.Index)
 
 	lineCount, err := p.LineCount()
 	if err != nil {
@@ -1509,7 +1717,19 @@ func (p *SunvoxPattern) Data() (*SunvoxPatternData, error) {
 		return nil, err
 	}
 
+	return p.data(lineCount, trackCount)
+}
+
+// data returns a view of the pattern's data with the given number of lines and tracks, which should have been read from
+// the pattern; unlike Data(), it doesn't pause the engine, so it can be called while the channel is locked.
+func (p *SunvoxPattern) data(lineCount, trackCount int) (*SunvoxPatternData, error) {
+	addr := getPatternData(p.Channel.Index, p.Index)
+	if addr == nil {
+		return nil, errors.New(fmt.Sprintf("error getting pattern data from channel %d and pattern %d", p.Channel.Index, p.Index))
+	}
+
 	res := &SunvoxPatternData{
+		pattern:    p,
 		trackCount: trackCount,
 		Data:       (unsafe.Slice(addr, lineCount*trackCount)),
 	}
@@ -1531,22 +1751,15 @@ func (c *SunvoxChannel) ModuleByName(moduleName string) *SunvoxModule {
 }
 
 // ForEachModule iterates through all modules in the project to execute a given function (forEach()) for
-// each module. If the function returns false, the function will stop iteration.
+// each module. If the function returns false, the function will stop iteration. See Modules() for an iterator.
 func (s *SunvoxChannel) ForEachModule(forEach func(module *SunvoxModule) bool) error {
-	modCount := 0
-	maxModCount, err := s.ModuleCount()
-	if err != nil {
-		return err
+	if slotCount := getNumberOfModuleSlots(s.Index); slotCount < 0 {
+		return errors.New(fmt.Sprintf("error getting module count for SunvoxChannel index %d; error code %d", s.Index, slotCount))
 	}
-	for i := 0; i < maxModCount; i++ {
-		mod := s.ModuleByIndex(i)
-		if mod == nil {
-			continue
-		}
+	for mod := range s.Modules() {
 		if !forEach(mod) {
 			break
 		}
-		modCount++
 	}
 	return nil
 }
@@ -1646,6 +1859,17 @@ func (c *SunvoxChannel) LPM() float32 {
 	return c.TPM() / float32(c.TPL())
 }
 
+// LinesPerBeat returns the number of lines in each beat of the project in the channel. This can be fractional,
+// depending on the TPL (for example, at 5 ticks per line, a beat is 4.8 lines long).
+func (c *SunvoxChannel) LinesPerBeat() float32 {
+	tpl := c.TPL()
+	if tpl <= 0 {
+		return 0
+	}
+	// In Sunvox, 1 beat = 24 ticks
+	return 24 / float32(tpl)
+}
+
 // SunvoxModule represents a module connected to other modules in a Sunvox project.
 type SunvoxModule struct {
 	Channel *SunvoxChannel
@@ -1670,7 +1894,7 @@ func (m *SunvoxModule) IsValid() bool {
 func (m *SunvoxModule) Flags() (int32, error) {
 	flags := getModuleFlags(m.Channel.Index, m.Index)
 	if flags < 0 {
-		return 0, errors.New(fmt.Sprintf("error retrieving flags for module %d of name %s in channel %d; error code %d", m.Index, m.Name(), m.Channel.Index))
+		return 0, errors.New(fmt.Sprintf("error retrieving flags for module %d of name %s in channel %d; error code %d", m.Index, m.Name(), m.Channel.Index, flags))
 	}
 	return flags, nil
 }
@@ -1680,6 +1904,20 @@ func (m *SunvoxModule) Flags() (int32, error) {
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (m *SunvoxModule) SetBSM(bypass, solo, mute bool) error {
+	flags, flagsErr := m.Flags()
+	if err := m.setBSM(bypass, solo, mute); err != nil {
+		return err
+	}
+	if flagsErr == nil {
+		previous := bsmState{bypass: flags&ModuleFlagBypass > 0, solo: flags&ModuleFlagSolo > 0, mute: flags&ModuleFlagMute > 0}
+		if current := (bsmState{bypass: bypass, solo: solo, mute: mute}); current != previous {
+			m.Channel.recordEdit("Set module BSM", bsmEdit(m, previous, current))
+		}
+	}
+	return nil
+}
+
+func (m *SunvoxModule) setBSM(bypass, solo, mute bool) error {
 	bsm := 0
 	if bypass {
 		bsm += 256
@@ -1759,6 +1997,18 @@ func (m *SunvoxModule) ControllerMaximum(ctrlNum int) (int, error) {
 // Controller #3 for an Analog Generator, panning, ranges from -128 to 128; to set this to 50% right would be:
 // channel.ModuleByName("Analog generator").SetControlValue(3, 64)
 func (m *SunvoxModule) SetControllerValue(ctrlNum, value int) error {
+	previous, previousErr := m.ControllerValue(ctrlNum)
+	if err := m.setControllerValue(ctrlNum, value); err != nil {
+		return err
+	}
+	if previousErr == nil && previous != value {
+		m.Channel.recordEdit("Set controller", controllerEdit(m, ctrlNum, previous, value))
+	}
+	return nil
+}
+
+// setControllerValue sets the controller's value without recording the change in the channel's edit journal.
+func (m *SunvoxModule) setControllerValue(ctrlNum, value int) error {
 	if ctrlNum <= 0 {
 		return errors.New(fmt.Sprintf("error setting control; controllers 0 and below don't exist"))
 	}
@@ -1774,20 +2024,28 @@ func (m *SunvoxModule) SetControllerValue(ctrlNum, value int) error {
 // will print exactly what the error might be).
 func (m *SunvoxModule) Connect(dest *SunvoxModule) error {
 
+	if err := m.Channel.Lock(); err != nil {
+		return err
+	}
+	defer m.Channel.Unlock()
+
+	return m.connect(dest)
+}
+
+func (m *SunvoxModule) connect(dest *SunvoxModule) error {
+
 	if dest == nil {
 		return errors.New(fmt.Sprintf("error connecting module %d (source) to destination module; it is nil", m.Index))
 	}
 
-	if err := m.Channel.Lock(); err != nil {
-		return err
-	}
+	connected := m.isConnectedTo(dest)
 
 	if res := connectModule(m.Channel.Index, m.Index, dest.Index); res < 0 {
 		return errors.New(fmt.Sprintf("error connecting module %d (source) to module %d (dest); error code %d", m.Index, dest.Index, res))
 	}
 
-	if err := m.Channel.Unlock(); err != nil {
-		return err
+	if !connected {
+		m.Channel.recordEdit("Connect modules", connectionEdit(m, dest, false))
 	}
 
 	return nil
@@ -1799,25 +2057,54 @@ func (m *SunvoxModule) Connect(dest *SunvoxModule) error {
 // will print exactly what the error might be).
 func (m *SunvoxModule) Disconnect(dest *SunvoxModule) error {
 
+	if err := m.Channel.Lock(); err != nil {
+		return err
+	}
+	defer m.Channel.Unlock()
+
+	return m.disconnect(dest)
+}
+
+func (m *SunvoxModule) disconnect(dest *SunvoxModule) error {
+
 	if dest == nil {
 		return errors.New(fmt.Sprintf("error disconnecting module %d (source) from destination module; it is nil", m.Index))
 	}
 
-	if err := m.Channel.Lock(); err != nil {
-		return err
-	}
+	connected := m.isConnectedTo(dest)
 
 	if res := disconnectModule(m.Channel.Index, m.Index, dest.Index); res < 0 {
 		return errors.New(fmt.Sprintf("error disconnecting module %d (source) to module %d (dest); error code %d", m.Index, dest.Index, res))
 	}
 
-	if err := m.Channel.Unlock(); err != nil {
-		return err
+	if connected {
+		m.Channel.recordEdit("Disconnect modules", connectionEdit(m, dest, true))
 	}
 
 	return nil
 }
 
+// isConnectedTo returns if the module's output is connected to the destination module's input.
+func (m *SunvoxModule) isConnectedTo(dest *SunvoxModule) bool {
+
+	flags := uint32(getModuleFlags(m.Channel.Index, m.Index))
+	count := int(flags & moduleOutputsMask >> moduleOutputsOffset)
+
+	outputs := getModuleOutputs(m.Channel.Index, m.Index)
+	if outputs == nil {
+		return false
+	}
+
+	for _, output := range unsafe.Slice(outputs, count) {
+		if int(output) == dest.Index {
+			return true
+		}
+	}
+
+	return false
+
+}
+
 // Finetune returns the finetune value of the Module.
 func (m *SunvoxModule) Finetune() uint32 {
 	f := getModuleFinetuneRelativeNote(m.Channel.Index, m.Index)
@@ -1844,6 +2131,18 @@ func (m *SunvoxModule) RelativeNote() uint32 {
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (m *SunvoxModule) SetFinetune(finetune int) error {
+	previous := int(int32(m.Finetune()))
+	if err := m.setFinetune(finetune); err != nil {
+		return err
+	}
+	if previous != finetune {
+		m.Channel.recordEdit("Set finetune", finetuneEdit(m, previous))
+	}
+	return nil
+}
+
+// setFinetune sets the module's finetune without recording the change in the channel's edit journal.
+func (m *SunvoxModule) setFinetune(finetune int) error {
 	err := setModuleFinetune(m.Channel.Index, m.Index, finetune)
 	if err > 0 {
 		return errors.New(fmt.Sprintf("error setting finetune for module %d value %d; error code %d", m.Index, finetune, err))
@@ -1856,6 +2155,18 @@ func (m *SunvoxModule) SetFinetune(finetune int) error {
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
 func (m *SunvoxModule) SetRelativeNote(relativeNote int) error {
+	previous := int(int32(m.RelativeNote()))
+	if err := m.setRelativeNote(relativeNote); err != nil {
+		return err
+	}
+	if previous != relativeNote {
+		m.Channel.recordEdit("Set relative note", relativeNoteEdit(m, previous))
+	}
+	return nil
+}
+
+// setRelativeNote sets the module's relative note without recording the change in the channel's edit journal.
+func (m *SunvoxModule) setRelativeNote(relativeNote int) error {
 	err := setModuleRelativeNote(m.Channel.Index, m.Index, relativeNote)
 	if err > 0 {
 		return errors.New(fmt.Sprintf("error setting finetune for module %d value %d; error code %d", m.Index, relativeNote, err))
@@ -1874,7 +2185,9 @@ type SunvoxPatternNoteData struct {
 
 // SunvoxPatternData represents note data for all lines for all tracks in a pattern's note data.
 type SunvoxPatternData struct {
+	pattern    *SunvoxPattern
 	trackCount int
+	original   []SunvoxPatternNoteData // The cells as they were when a snapshot was taken or last committed
 	Data       []SunvoxPatternNoteData
 }
 
@@ -1890,14 +2203,51 @@ func (s SunvoxPatternData) TrackCount() int {
 
 func (s SunvoxPatternData) noteData(trackNum, lineNum int) (*SunvoxPatternNoteData, error) {
 	i := trackNum + (lineNum * s.trackCount)
-	if i < 0 || i > len(s.Data) {
-		return nil, errors.New("track number %d or line number %d outside of the range of the pattern")
+	if trackNum < 0 || trackNum >= s.trackCount || i < 0 || i >= len(s.Data) {
+		return nil, errors.New(fmt.Sprintf("error getting pattern data; track number %d or line number %d is outside of the range of the pattern", trackNum, lineNum))
 	}
 	return &s.Data[i], nil
 }
 
+// recordCell records the current value of the cell at the given track and line in the channel's edit journal,
+// before it's changed.
+func (s SunvoxPatternData) recordCell(trackNum, lineNum int) {
+	if s.pattern != nil {
+		s.pattern.Channel.recordEdit("Edit pattern", eventEdit(s.pattern, trackNum, lineNum, s.Data[trackNum+(lineNum*s.trackCount)]))
+	}
+}
+
+// setCell sets the cell at the given index, recording the change in the channel's edit journal.
+func (s SunvoxPatternData) setCell(index int, cell SunvoxPatternNoteData) {
+	if s.Data[index] == cell {
+		return
+	}
+	if s.pattern != nil {
+		s.pattern.Channel.recordEdit("Edit pattern", eventEdit(s.pattern, index%s.trackCount, index/s.trackCount, s.Data[index]))
+	}
+	s.Data[index] = cell
+	s.changed()
+}
+
+// changed clears the pattern's cached notes (see SunvoxChannel.RefreshNotes()) after its data has been changed.
+func (s SunvoxPatternData) changed() {
+	if s.pattern != nil {
+		s.pattern.Channel.invalidateNotes(s.pattern.Index)
+	}
+}
+
+// editStep groups the edits to the SunvoxPatternData made by a bulk operation into an edit step of the given name,
+// returning the function that ends the step.
+func (s SunvoxPatternData) editStep(name string) func() {
+	if s.pattern == nil {
+		return func() {}
+	}
+	s.pattern.Channel.BeginEditStep(name)
+	return s.pattern.Channel.EndEditStep
+}
+
 // Note returns the note of the track and line given, from hexadecimal.
-// C5 is 61.
+// C5 is 61; see the Note type for working with note values.
 // If the function is unable to execute for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
@@ -1911,7 +2261,7 @@ func (s SunvoxPatternData) Note(trackNum, lineNum int) (uint8, error) {
 
 // SetNote sets the note for the given note data to the value specified.
 // You can use the NoteCommand constants for special note types.
-// C5 is 61.
+// C5 is 61; see the Note type for working with note values.
 // If the function is unable to execute for whatever reason, the function returns an
 // error code (and, if the SunvoxEngine is initialized in debug mode (which is the default), the engine
 // will print exactly what the error might be).
@@ -1920,7 +2270,11 @@ func (s SunvoxPatternData) SetNote(trackNum, lineNum int, noteValue uint8) error
 	if err != nil {
 		return err
 	}
-	note.Note = noteValue
+	if note.Note != noteValue {
+		s.recordCell(trackNum, lineNum)
+		note.Note = noteValue
+		s.changed()
+	}
 	return nil
 }
 
@@ -1945,7 +2299,11 @@ func (s SunvoxPatternData) SetVelocity(trackNum, lineNum int, velocity uint8) er
 	if err != nil {
 		return err
 	}
-	note.Velocity = velocity
+	if note.Velocity != velocity {
+		s.recordCell(trackNum, lineNum)
+		note.Velocity = velocity
+		s.changed()
+	}
 	return nil
 }
 
@@ -1970,7 +2328,11 @@ func (s SunvoxPatternData) SetModule(trackNum, lineNum int, moduleNumber uint16)
 	if err != nil {
 		return err
 	}
-	note.Module = moduleNumber + 1
+	if note.Module != moduleNumber+1 {
+		s.recordCell(trackNum, lineNum)
+		note.Module = moduleNumber + 1
+		s.changed()
+	}
 	return nil
 }
 
@@ -1995,7 +2357,11 @@ func (s SunvoxPatternData) SetController(trackNum, lineNum int, controllerNumber
 	if err != nil {
 		return err
 	}
-	note.Controller = controllerNumber - 1
+	if note.Controller != controllerNumber-1 {
+		s.recordCell(trackNum, lineNum)
+		note.Controller = controllerNumber - 1
+		s.changed()
+	}
 	return nil
 }
 
@@ -2021,6 +2387,10 @@ func (s SunvoxPatternData) SetControllerValue(trackNum, lineNum int, value uint1
 	if err != nil {
 		return err
 	}
-	note.ControllerValue = value
+	if note.ControllerValue != value {
+		s.recordCell(trackNum, lineNum)
+		note.ControllerValue = value
+		s.changed()
+	}
 	return nil
 }
@@ -0,0 +1,165 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrorTransactionFinished = errors.New("error: the transaction has already finished")
+
+// ChannelTx represents a batch of edits to a SunvoxChannel, made while the channel's audio engine is paused and the channel
+// is locked. ChannelTx objects are only valid inside of the function passed to SunvoxChannel.Batch().
+type ChannelTx struct {
+	Channel  *SunvoxChannel
+	finished bool
+}
+
+// Batch pauses the audio engine and locks the SunvoxChannel once, and then executes the given function with a ChannelTx
+// that can be used to make edits to the channel. Afterwards, the channel is unlocked and the audio engine resumed, even if
+// the function returns an error or panics. Batch returns the error returned by the function.
+//
+// This is much faster than calling functions that pause and lock the channel individually (like SunvoxPattern.SetXY())
+// many times in a row. Note that the ChannelTx's functions should be used for edits within the function rather than the
+// SunvoxChannel's own.
+//
+// If the SunvoxChannel's edit journal is enabled, the edits made within the batch are grouped into a single edit step.
+func (s *SunvoxChannel) Batch(function func(tx *ChannelTx) error) error {
+
+	// It's faster to make changes while the audio engine is paused, regardless of if a song is playing.
+	s.pauseEngine()
+	defer s.resumeEngine()
+
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	// The batch's edits are undone and redone together
+	s.BeginEditStep("")
+	defer s.EndEditStep()
+
+	tx := &ChannelTx{Channel: s}
+	defer func() { tx.finished = true }()
+
+	return function(tx)
+
+}
+
+// QueueBatch queues Batch() to be executed on the SunvoxChannel's background worker goroutine. See Queue() for more information.
+func (s *SunvoxChannel) QueueBatch(function func(tx *ChannelTx) error) *Future {
+	return s.Queue(func() error { return s.Batch(function) })
+}
+
+// Play plays the song contained within the SunvoxChannel from wherever the playhead currently is.
+func (tx *ChannelTx) Play() error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.doPlay()
+}
+
+// PlayFromBeginning plays the song contained within the SunvoxChannel from the beginning (line number 0).
+func (tx *ChannelTx) PlayFromBeginning() error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.doPlayFromBeginning()
+}
+
+// Stop stops audio playback that is currently playing back through the SunvoxChannel.
+func (tx *ChannelTx) Stop() error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.doStop()
+}
+
+// Seek seeks playback to the given line number.
+func (tx *ChannelTx) Seek(lineNum int) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.doSeek(lineNum)
+}
+
+// SetLooping sets whether the SunvoxChannel should loop.
+func (tx *ChannelTx) SetLooping(loop bool) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	if loop == tx.Channel.IsLooping() {
+		return nil
+	}
+	return tx.Channel.doSetLooping(loop)
+}
+
+// SetBPM sets the BPM for playback in the channel. See SunvoxChannel.SetBPM() for more information.
+func (tx *ChannelTx) SetBPM(bpm float32) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.SetBPM(bpm)
+}
+
+// SetTPL sets the TPL (ticks per line) for the project. See SunvoxChannel.SetTPL() for more information.
+func (tx *ChannelTx) SetTPL(tpl int) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return tx.Channel.SetTPL(tpl)
+}
+
+// SetPatternXY sets the X (line position) and Y of the given pattern to the given values.
+func (tx *ChannelTx) SetPatternXY(pattern *SunvoxPattern, x, y int) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	previousX, previousY := pattern.X(), pattern.Y()
+	res := setPatternXY(tx.Channel.Index, pattern.Index, x, y)
+	tx.Channel.invalidateMarkers()
+	if res != 0 {
+		return errors.New(fmt.Sprintf("error setting pattern %d x, y to %d, %d in channel %d; error code %d", pattern.Index, x, y, tx.Channel.Index, res))
+	}
+	if previousX != x || previousY != y {
+		tx.Channel.recordEdit("Move pattern", patternXYEdit(pattern, previousX, previousY))
+	}
+	return nil
+}
+
+// MovePattern moves the given pattern by the dx (with dx being in lines) and dy values specified.
+func (tx *ChannelTx) MovePattern(pattern *SunvoxPattern, dx, dy int) error {
+	return tx.SetPatternXY(pattern, pattern.X()+dx, pattern.Y()+dy)
+}
+
+// SetPatternMute sets the given pattern to be muted (or not). It returns whether the pattern was previously muted or not.
+func (tx *ChannelTx) SetPatternMute(pattern *SunvoxPattern, muted bool) (bool, error) {
+	if tx.finished {
+		return false, ErrorTransactionFinished
+	}
+	return pattern.setMute(muted)
+}
+
+// Connect connects the source Module to the destination Module.
+func (tx *ChannelTx) Connect(source, dest *SunvoxModule) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return source.connect(dest)
+}
+
+// Disconnect disconnects the source Module from the destination Module.
+func (tx *ChannelTx) Disconnect(source, dest *SunvoxModule) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return source.disconnect(dest)
+}
+
+// SetControllerValue sets the numbered controller of the given module to the value indicated.
+// See SunvoxModule.SetControllerValue() for more information.
+func (tx *ChannelTx) SetControllerValue(module *SunvoxModule, ctrlNum, value int) error {
+	if tx.finished {
+		return ErrorTransactionFinished
+	}
+	return module.SetControllerValue(ctrlNum, value)
+}
@@ -0,0 +1,549 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrorInvalidClip = errors.New("error: the clip's data doesn't hold its number of tracks and lines")
+
+// PatternRegion represents a rectangular region of a pattern's tracks and lines. The zero value covers the whole pattern.
+type PatternRegion struct {
+	Track  int // The first track of the region
+	Line   int // The first line of the region
+	Tracks int // The number of tracks in the region; if it's less than or equal to 0, the region extends to the last track
+	Lines  int // The number of lines in the region; if it's less than or equal to 0, the region extends to the last line
+}
+
+// bounds returns the tracks and lines the PatternRegion covers in the given pattern data, clipped to the data; the end
+// values are exclusive.
+func (r PatternRegion) bounds(data SunvoxPatternData) (track, line, trackEnd, lineEnd int) {
+
+	track = max(r.Track, 0)
+	line = max(r.Line, 0)
+
+	trackEnd = data.TrackCount()
+	if r.Tracks > 0 {
+		trackEnd = min(r.Track+r.Tracks, trackEnd)
+	}
+
+	lineEnd = data.LineCount()
+	if r.Lines > 0 {
+		lineEnd = min(r.Line+r.Lines, lineEnd)
+	}
+
+	return track, line, max(trackEnd, track), max(lineEnd, line)
+
+}
+
+// PatternFilter selects the cells of patterns that an edit applies to. The zero value selects every cell of every pattern.
+type PatternFilter struct {
+	Region  PatternRegion                         // The region of each pattern to select cells from
+	Modules []*SunvoxModule                       // If set, only cells that play one of these modules are selected
+	Match   func(cell SunvoxPatternNoteData) bool // If set, only cells it returns true for are selected
+	Pattern func(pattern *SunvoxPattern) bool     // If set, only patterns it returns true for are edited (for SunvoxChannel edits)
+}
+
+// PatternsInLanes returns a function for PatternFilter.Pattern (or SunvoxChannel.Patterns()) that selects the patterns with Y positions from minY to
+// maxY (inclusive), like the lanes of patterns above or below a song's main melody.
+func PatternsInLanes(minY, maxY int) func(pattern *SunvoxPattern) bool {
+	return func(pattern *SunvoxPattern) bool {
+		y := pattern.Y()
+		return y >= minY && y <= maxY
+	}
+}
+
+// selects returns if the PatternFilter selects the given cell (aside from its region).
+func (f PatternFilter) selects(cell SunvoxPatternNoteData) bool {
+	if len(f.Modules) > 0 && !slices.ContainsFunc(f.Modules, func(module *SunvoxModule) bool { return int(cell.Module) == module.Index+1 }) {
+		return false
+	}
+	return f.Match == nil || f.Match(cell)
+}
+
+// PatternCell represents a cell of a pattern, as found by SunvoxPatternData.Find() or SunvoxChannel.FindInPatterns().
+type PatternCell struct {
+	Pattern *SunvoxPattern // The pattern the cell is in; this is nil if the SunvoxPatternData wasn't read from a pattern
+	Track   int
+	Line    int
+	SunvoxPatternNoteData
+}
+
+// IsEmpty returns if the cell has no note, velocity, module, controller or value set.
+func (n SunvoxPatternNoteData) IsEmpty() bool {
+	return n == SunvoxPatternNoteData{}
+}
+
+// each calls the given function with the index (into Data) of each cell of the SunvoxPatternData that the filter
+// selects, returning the number of times the function returned true.
+func (s SunvoxPatternData) each(filter PatternFilter, forEach func(index int) bool) int {
+	count := 0
+	track, line, trackEnd, lineEnd := filter.Region.bounds(s)
+	for l := line; l < lineEnd; l++ {
+		for t := track; t < trackEnd; t++ {
+			index := t + l*s.trackCount
+			if filter.selects(s.Data[index]) && forEach(index) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Transpose transposes the notes of the cells the filter selects by the given interval, returning the number of notes
+// changed. Note commands are left as they are, as are notes that would be transposed out of the range of playable notes.
+func (s SunvoxPatternData) Transpose(filter PatternFilter, interval Interval) int {
+	defer s.editStep("Transpose")()
+	return s.each(filter, func(index int) bool {
+		note, ok := Note(s.Data[index].Note).Transpose(interval)
+		if !ok || note == Note(s.Data[index].Note) {
+			return false
+		}
+		cell := s.Data[index]
+		cell.Note = uint8(note)
+		s.setCell(index, cell)
+		return true
+	})
+}
+
+// ScaleVelocity multiplies the velocities of the notes the filter selects by the given scale, clamping them from 1 to
+// 129, and returns the number of notes changed. Notes with the default velocity (0) are treated as having the maximum
+// velocity (129).
+func (s SunvoxPatternData) ScaleVelocity(filter PatternFilter, scale float64) int {
+	defer s.editStep("Scale velocity")()
+	return s.each(filter, func(index int) bool {
+		cell := s.Data[index]
+		if !Note(cell.Note).IsValid() {
+			return false
+		}
+		velocity := int(cell.Velocity)
+		if velocity == 0 {
+			velocity = 129
+		}
+		scaled := uint8(min(max(int(float64(velocity)*scale+0.5), 1), 129))
+		if scaled == cell.Velocity {
+			return false
+		}
+		cell.Velocity = scaled
+		s.setCell(index, cell)
+		return true
+	})
+}
+
+// Clear clears the cells the filter selects, returning the number of cells cleared.
+func (s SunvoxPatternData) Clear(filter PatternFilter) int {
+	defer s.editStep("Clear")()
+	return s.each(filter, func(index int) bool {
+		if s.Data[index].IsEmpty() {
+			return false
+		}
+		s.setCell(index, SunvoxPatternNoteData{})
+		return true
+	})
+}
+
+// Find returns the cells the filter selects, ordered by line and then by track. Empty cells are only returned if
+// the filter has a Match function.
+func (s SunvoxPatternData) Find(filter PatternFilter) []PatternCell {
+	cells := []PatternCell{}
+	s.each(filter, func(index int) bool {
+		if filter.Match != nil || !s.Data[index].IsEmpty() {
+			cells = append(cells, PatternCell{
+				Pattern:               s.pattern,
+				Track:                 index % s.trackCount,
+				Line:                  index / s.trackCount,
+				SunvoxPatternNoteData: s.Data[index],
+			})
+		}
+		return false
+	})
+	return cells
+}
+
+// Replace replaces each of the cells the filter selects with the cell returned from the replace function, returning
+// the number of cells changed.
+func (s SunvoxPatternData) Replace(filter PatternFilter, replace func(cell SunvoxPatternNoteData) SunvoxPatternNoteData) int {
+	defer s.editStep("Replace")()
+	return s.each(filter, func(index int) bool {
+		replaced := replace(s.Data[index])
+		if replaced == s.Data[index] {
+			return false
+		}
+		s.setCell(index, replaced)
+		return true
+	})
+}
+
+// ReplaceNote replaces the given note with another in the cells the filter selects, returning the number of cells changed.
+func (s SunvoxPatternData) ReplaceNote(filter PatternFilter, from, to Note) int {
+	return s.Replace(filter, func(cell SunvoxPatternNoteData) SunvoxPatternNoteData {
+		if Note(cell.Note) == from {
+			cell.Note = uint8(to)
+		}
+		return cell
+	})
+}
+
+// ReplaceModule replaces the given module with another in the cells the filter selects, returning the number of cells
+// changed. Modules are given as their index + 1 (as in pattern data), so 0 is no module.
+func (s SunvoxPatternData) ReplaceModule(filter PatternFilter, from, to uint16) int {
+	return s.Replace(filter, func(cell SunvoxPatternNoteData) SunvoxPatternNoteData {
+		if cell.Module == from {
+			cell.Module = to
+		}
+		return cell
+	})
+}
+
+// ReplaceController replaces the given controller / effect (the CCEE column) with another in the cells the filter
+// selects, returning the number of cells changed.
+func (s SunvoxPatternData) ReplaceController(filter PatternFilter, from, to uint16) int {
+	return s.Replace(filter, func(cell SunvoxPatternNoteData) SunvoxPatternNoteData {
+		if cell.Controller == from {
+			cell.Controller = to
+		}
+		return cell
+	})
+}
+
+// Fill sets every given number of lines in the region to the given cell, starting from the region's first line (so an
+// every value of 4 fills lines 0, 4, 8, and so on). If every is less than or equal to 0, it's treated as 1.
+func (s SunvoxPatternData) Fill(region PatternRegion, cell SunvoxPatternNoteData, every int) {
+	defer s.editStep("Fill")()
+	track, line, trackEnd, lineEnd := region.bounds(s)
+	for l := line; l < lineEnd; l += max(every, 1) {
+		for t := track; t < trackEnd; t++ {
+			s.setCell(t+l*s.trackCount, cell)
+		}
+	}
+}
+
+// Reverse reverses the order of the lines in the region.
+func (s SunvoxPatternData) Reverse(region PatternRegion) {
+	defer s.editStep("Reverse")()
+	s.editColumns(region, func(column []SunvoxPatternNoteData) {
+		slices.Reverse(column)
+	})
+}
+
+// Rotate moves the lines in the region down by the given number of lines (or up, if it's negative), wrapping lines
+// that are moved past one end of the region around to the other.
+func (s SunvoxPatternData) Rotate(region PatternRegion, lines int) {
+	defer s.editStep("Rotate")()
+	s.editColumns(region, func(column []SunvoxPatternNoteData) {
+		if len(column) == 0 {
+			return
+		}
+		shift := lines % len(column)
+		if shift < 0 {
+			shift += len(column)
+		}
+		slices.Reverse(column)
+		slices.Reverse(column[:shift])
+		slices.Reverse(column[shift:])
+	})
+}
+
+// Shift moves the lines in the region down by the given number of lines (or up, if it's negative). Lines that are
+// moved past the end of the region are removed, and the lines left behind are cleared.
+func (s SunvoxPatternData) Shift(region PatternRegion, lines int) {
+	defer s.editStep("Shift")()
+	s.editColumns(region, func(column []SunvoxPatternNoteData) {
+		shifted := make([]SunvoxPatternNoteData, len(column))
+		for i, cell := range column {
+			if j := i + lines; j >= 0 && j < len(column) {
+				shifted[j] = cell
+			}
+		}
+		copy(column, shifted)
+	})
+}
+
+// Quantize moves the cells in the region to the closest line on a grid of the given number of lines, counting from the
+// start of the region, returning the number of cells removed. If several cells in a track would land on the same line,
+// the one closest to it is kept (or the earliest, if they're equally close), and the others are removed. If grid is less
+// than or equal to 1, nothing is changed.
+func (s SunvoxPatternData) Quantize(region PatternRegion, grid int) int {
+
+	if grid <= 1 {
+		return 0
+	}
+
+	defer s.editStep("Quantize")()
+
+	removed := 0
+
+	s.editColumns(region, func(column []SunvoxPatternNoteData) {
+
+		quantized := make([]SunvoxPatternNoteData, len(column))
+		distances := make([]int, len(column))
+
+		for i, cell := range column {
+
+			if cell.IsEmpty() {
+				continue
+			}
+
+			target := (i + grid/2) / grid * grid
+			if target >= len(column) {
+				target -= grid
+			}
+
+			distance := abs(target - i)
+
+			if !quantized[target].IsEmpty() {
+				removed++
+				if distances[target] <= distance {
+					continue
+				}
+			}
+
+			quantized[target] = cell
+			distances[target] = distance
+
+		}
+
+		copy(column, quantized)
+
+	})
+
+	return removed
+
+}
+
+// editColumns calls the given function with the cells of each track within the region, from the first line to the last,
+// and writes the cells back afterwards.
+func (s SunvoxPatternData) editColumns(region PatternRegion, edit func(column []SunvoxPatternNoteData)) {
+	track, line, trackEnd, lineEnd := region.bounds(s)
+	column := make([]SunvoxPatternNoteData, lineEnd-line)
+	for t := track; t < trackEnd; t++ {
+		for l := line; l < lineEnd; l++ {
+			column[l-line] = s.Data[t+l*s.trackCount]
+		}
+		edit(column)
+		for l := line; l < lineEnd; l++ {
+			s.setCell(t+l*s.trackCount, column[l-line])
+		}
+	}
+}
+
+// PatternClip holds a copy of a rectangular region of pattern data, which can be pasted into other patterns (see
+// SunvoxPatternData.Copy() and SunvoxPatternData.Paste()).
+type PatternClip struct {
+	Tracks int
+	Lines  int
+	Data   []SunvoxPatternNoteData // The clip's cells, line by line
+
+	Channel *SunvoxChannel // The channel the clip was copied from, which its module numbers refer to
+}
+
+// Copy returns a copy of the cells in the given region as a PatternClip.
+func (s SunvoxPatternData) Copy(region PatternRegion) PatternClip {
+	track, line, trackEnd, lineEnd := region.bounds(s)
+	clip := PatternClip{
+		Tracks: trackEnd - track,
+		Lines:  lineEnd - line,
+		Data:   make([]SunvoxPatternNoteData, 0, (trackEnd-track)*(lineEnd-line)),
+	}
+	for l := line; l < lineEnd; l++ {
+		clip.Data = append(clip.Data, s.Data[track+l*s.trackCount:trackEnd+l*s.trackCount]...)
+	}
+	return clip
+}
+
+// Paste pastes the PatternClip into the SunvoxPatternData with its top-left cell at the given track and line. Cells that
+// would land outside of the pattern are left out. If mix is true, empty cells in the clip are skipped, so they don't
+// overwrite the cells under them. If the clip's Data is shorter than its Tracks and Lines call for, ErrorInvalidClip is
+// returned and nothing is pasted.
+func (s SunvoxPatternData) Paste(clip PatternClip, track, line int, mix bool) error {
+	if clip.Tracks < 0 || clip.Lines < 0 || len(clip.Data) < clip.Tracks*clip.Lines {
+		return ErrorInvalidClip
+	}
+	defer s.editStep("Paste")()
+	for l := range clip.Lines {
+		for t := range clip.Tracks {
+			dt, dl := track+t, line+l
+			if dt < 0 || dt >= s.trackCount || dl < 0 || dl >= s.LineCount() {
+				continue
+			}
+			cell := clip.Data[t+l*clip.Tracks]
+			if mix && cell.IsEmpty() {
+				continue
+			}
+			s.setCell(dt+dl*s.trackCount, cell)
+		}
+	}
+	return nil
+}
+
+// RemapModules returns a copy of the PatternClip with its module numbers changed to refer to the modules of the same
+// names in the given channel, so that it can be pasted into that channel's patterns. Modules that the given channel
+// doesn't have a module of the same name for are left as they are. If the clip's Channel isn't set, the clip is
+// returned unchanged.
+func (c PatternClip) RemapModules(channel *SunvoxChannel) PatternClip {
+
+	remapped := c
+	remapped.Data = slices.Clone(c.Data)
+	remapped.Channel = channel
+
+	if c.Channel == nil || c.Channel == channel {
+		return remapped
+	}
+
+	modules := map[uint16]uint16{}
+
+	for i, cell := range remapped.Data {
+		if cell.Module == 0 {
+			continue
+		}
+		number, ok := modules[cell.Module]
+		if !ok {
+			number = cell.Module
+			if source := c.Channel.ModuleByIndex(int(cell.Module) - 1); source != nil {
+				if dest := channel.ModuleByName(source.Name()); dest != nil {
+					number = uint16(dest.Index + 1)
+				}
+			}
+			modules[cell.Module] = number
+		}
+		remapped.Data[i].Module = number
+	}
+
+	return remapped
+
+}
+
+// CopyFrom returns a copy of the cells in the given region of the pattern as a PatternClip.
+func (p *SunvoxPattern) CopyFrom(region PatternRegion) (PatternClip, error) {
+	if err := p.Channel.Lock(); err != nil {
+		return PatternClip{}, err
+	}
+	defer p.Channel.Unlock()
+	data, err := p.Data()
+	if err != nil {
+		return PatternClip{}, err
+	}
+	clip := data.Copy(region)
+	clip.Channel = p.Channel
+	return clip, nil
+}
+
+// PasteInto pastes the PatternClip into the pattern with its top-left cell at the given track and line (see
+// SunvoxPatternData.Paste()). If the clip was copied from another channel, its modules are remapped to the modules of
+// the same names in the pattern's channel first (see PatternClip.RemapModules()).
+func (p *SunvoxPattern) PasteInto(clip PatternClip, track, line int, mix bool) error {
+	if err := p.Channel.Lock(); err != nil {
+		return err
+	}
+	defer p.Channel.Unlock()
+	data, err := p.Data()
+	if err != nil {
+		return err
+	}
+	return data.Paste(clip.RemapModules(p.Channel), track, line, mix)
+}
+
+// EditPatterns calls the given function with the data of each of the SunvoxChannel's patterns that the filter selects
+// (through its Pattern function), while the channel is locked so that the edits don't clash with playback.
+//
+// If the SunvoxChannel's edit journal is enabled, the edits are grouped into a single edit step.
+func (s *SunvoxChannel) EditPatterns(filter PatternFilter, edit func(pattern *SunvoxPattern, data *SunvoxPatternData)) {
+	s.editPatterns("Edit patterns", filter, edit)
+}
+
+// editPatterns works as EditPatterns(), grouping the edits into an edit step with the given name.
+func (s *SunvoxChannel) editPatterns(name string, filter PatternFilter, edit func(pattern *SunvoxPattern, data *SunvoxPatternData)) {
+
+	// If the channel can't be locked, nothing is edited
+	if err := s.Lock(); err != nil {
+		return
+	}
+	defer s.Unlock()
+
+	s.BeginEditStep(name)
+	defer s.EndEditStep()
+
+	s.forEachPatternData(filter, edit)
+
+}
+
+// readPatterns calls the given function with the data of each of the SunvoxChannel's patterns that the filter selects,
+// while the channel is locked, as with EditPatterns(); as the data is only read, no edit step is recorded.
+func (s *SunvoxChannel) readPatterns(filter PatternFilter, read func(pattern *SunvoxPattern, data *SunvoxPatternData)) {
+
+	// If the channel can't be locked, nothing is read
+	if err := s.Lock(); err != nil {
+		return
+	}
+	defer s.Unlock()
+
+	s.forEachPatternData(filter, read)
+
+}
+
+// forEachPatternData calls the given function with the data of each of the SunvoxChannel's patterns that the filter
+// selects. The channel must be locked.
+func (s *SunvoxChannel) forEachPatternData(filter PatternFilter, forEach func(pattern *SunvoxPattern, data *SunvoxPatternData)) {
+	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+		if filter.Pattern != nil && !filter.Pattern(pattern) {
+			return true
+		}
+		if data, err := pattern.Data(); err == nil {
+			forEach(pattern, data)
+		}
+		return true
+	})
+}
+
+// TransposePatterns transposes the notes of the SunvoxChannel's patterns that the filter selects by the given interval
+// (see SunvoxPatternData.Transpose()), returning the number of notes changed.
+func (s *SunvoxChannel) TransposePatterns(filter PatternFilter, interval Interval) int {
+	count := 0
+	s.editPatterns("Transpose patterns", filter, func(pattern *SunvoxPattern, data *SunvoxPatternData) {
+		count += data.Transpose(filter, interval)
+	})
+	return count
+}
+
+// ScalePatternVelocities scales the velocities of the notes of the SunvoxChannel's patterns that the filter selects (see
+// SunvoxPatternData.ScaleVelocity()), returning the number of notes changed.
+func (s *SunvoxChannel) ScalePatternVelocities(filter PatternFilter, scale float64) int {
+	count := 0
+	s.editPatterns("Scale pattern velocities", filter, func(pattern *SunvoxPattern, data *SunvoxPatternData) {
+		count += data.ScaleVelocity(filter, scale)
+	})
+	return count
+}
+
+// ClearPatterns clears the cells of the SunvoxChannel's patterns that the filter selects, returning the number of cells
+// cleared.
+func (s *SunvoxChannel) ClearPatterns(filter PatternFilter) int {
+	count := 0
+	s.editPatterns("Clear patterns", filter, func(pattern *SunvoxPattern, data *SunvoxPatternData) {
+		count += data.Clear(filter)
+	})
+	return count
+}
+
+// FindInPatterns returns the cells of the SunvoxChannel's patterns that the filter selects (see SunvoxPatternData.Find()),
+// ordered by pattern, and then by line and track.
+func (s *SunvoxChannel) FindInPatterns(filter PatternFilter) []PatternCell {
+	cells := []PatternCell{}
+	s.readPatterns(filter, func(pattern *SunvoxPattern, data *SunvoxPatternData) {
+		for _, cell := range data.Find(filter) {
+			cell.Pattern = pattern
+			cells = append(cells, cell)
+		}
+	})
+	return cells
+}
+
+// ReplaceInPatterns replaces each of the cells of the SunvoxChannel's patterns that the filter selects with the cell
+// returned from the replace function (see SunvoxPatternData.Replace()), returning the number of cells changed.
+func (s *SunvoxChannel) ReplaceInPatterns(filter PatternFilter, replace func(cell SunvoxPatternNoteData) SunvoxPatternNoteData) int {
+	count := 0
+	s.editPatterns("Replace in patterns", filter, func(pattern *SunvoxPattern, data *SunvoxPatternData) {
+		count += data.Replace(filter, replace)
+	})
+	return count
+}
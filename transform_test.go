@@ -0,0 +1,143 @@
+package sunvoxgo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// testPatternData returns pattern data that isn't read from a pattern with the given number of tracks, with a cell for
+// each of the given notes (line by line).
+func testPatternData(trackCount int, notes ...uint8) SunvoxPatternData {
+	data := SunvoxPatternData{trackCount: trackCount, Data: make([]SunvoxPatternNoteData, len(notes))}
+	for i, note := range notes {
+		data.Data[i].Note = note
+	}
+	return data
+}
+
+// cellNotes returns the notes of each of the cells of the given pattern data, line by line.
+func cellNotes(data SunvoxPatternData) []uint8 {
+	notes := make([]uint8, len(data.Data))
+	for i, cell := range data.Data {
+		notes[i] = cell.Note
+	}
+	return notes
+}
+
+func TestQuantize(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		tracks      int
+		notes       []uint8
+		region      PatternRegion
+		grid        int
+		want        []uint8
+		wantRemoved int
+	}{
+		{name: "onto the grid", tracks: 1, notes: []uint8{0, 1, 0, 0, 0, 0, 0, 0}, grid: 4, want: []uint8{1, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "halfway rounds up", tracks: 1, notes: []uint8{0, 0, 1, 0, 0, 0, 0, 0}, grid: 4, want: []uint8{0, 0, 0, 0, 1, 0, 0, 0}},
+		{name: "closest kept", tracks: 1, notes: []uint8{0, 0, 0, 0, 1, 2, 0, 0}, grid: 4, want: []uint8{0, 0, 0, 0, 1, 0, 0, 0}, wantRemoved: 1},
+		{name: "earliest kept on a tie", tracks: 1, notes: []uint8{0, 0, 0, 1, 0, 2, 0, 0}, grid: 4, want: []uint8{0, 0, 0, 0, 1, 0, 0, 0}, wantRemoved: 1},
+		{name: "past the end moves back", tracks: 1, notes: []uint8{0, 0, 0, 0, 0, 0, 0, 1}, grid: 4, want: []uint8{0, 0, 0, 0, 1, 0, 0, 0}},
+		{name: "closer note after the end kept", tracks: 1, notes: []uint8{0, 0, 0, 0, 0, 0, 2, 1}, grid: 4, want: []uint8{0, 0, 0, 0, 2, 0, 0, 0}, wantRemoved: 1},
+		{name: "tracks quantized separately", tracks: 2, notes: []uint8{0, 0, 1, 0, 0, 0, 0, 2}, grid: 2, want: []uint8{0, 0, 0, 0, 1, 2, 0, 0}},
+		{name: "grid counts from the region", tracks: 1, notes: []uint8{0, 0, 0, 1, 0, 0, 0, 0}, region: PatternRegion{Line: 2, Lines: 4}, grid: 4, want: []uint8{0, 0, 1, 0, 0, 0, 0, 0}},
+		{name: "outside the region", tracks: 1, notes: []uint8{0, 1, 0, 0, 0, 0, 0, 0}, region: PatternRegion{Line: 2}, grid: 4, want: []uint8{0, 1, 0, 0, 0, 0, 0, 0}},
+		{name: "grid of 1", tracks: 1, notes: []uint8{0, 1, 0, 1}, grid: 1, want: []uint8{0, 1, 0, 1}},
+		{name: "grid of 0", tracks: 1, notes: []uint8{0, 1, 0, 1}, grid: 0, want: []uint8{0, 1, 0, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testPatternData(test.tracks, test.notes...)
+			removed := data.Quantize(test.region, test.grid)
+			if got := cellNotes(data); !slices.Equal(got, test.want) {
+				t.Errorf("got notes %v, want %v", got, test.want)
+			}
+			if removed != test.wantRemoved {
+				t.Errorf("got %d cells removed, want %d", removed, test.wantRemoved)
+			}
+		})
+	}
+
+}
+
+func TestRotateAndShift(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		shift  bool // Whether to shift rather than rotate
+		tracks int
+		notes  []uint8
+		region PatternRegion
+		lines  int
+		want   []uint8
+	}{
+		{name: "rotate down", notes: []uint8{1, 2, 3, 4}, lines: 1, want: []uint8{4, 1, 2, 3}},
+		{name: "rotate up", notes: []uint8{1, 2, 3, 4}, lines: -1, want: []uint8{2, 3, 4, 1}},
+		{name: "rotate past the length", notes: []uint8{1, 2, 3, 4}, lines: 5, want: []uint8{4, 1, 2, 3}},
+		{name: "rotate up past the length", notes: []uint8{1, 2, 3, 4}, lines: -6, want: []uint8{3, 4, 1, 2}},
+		{name: "rotate by the length", notes: []uint8{1, 2, 3, 4}, lines: 4, want: []uint8{1, 2, 3, 4}},
+		{name: "rotate within a region", notes: []uint8{1, 2, 3, 4, 5}, region: PatternRegion{Line: 1, Lines: 3}, lines: 1, want: []uint8{1, 4, 2, 3, 5}},
+		{name: "rotate tracks separately", tracks: 2, notes: []uint8{1, 5, 2, 6, 3, 7}, lines: 1, want: []uint8{3, 7, 1, 5, 2, 6}},
+		{name: "rotate one track", tracks: 2, notes: []uint8{1, 5, 2, 6, 3, 7}, region: PatternRegion{Track: 1, Tracks: 1}, lines: -1, want: []uint8{1, 6, 2, 7, 3, 5}},
+		{name: "shift down", shift: true, notes: []uint8{1, 2, 3, 4}, lines: 1, want: []uint8{0, 1, 2, 3}},
+		{name: "shift up", shift: true, notes: []uint8{1, 2, 3, 4}, lines: -2, want: []uint8{3, 4, 0, 0}},
+		{name: "shift past the end", shift: true, notes: []uint8{1, 2, 3, 4}, lines: 4, want: []uint8{0, 0, 0, 0}},
+		{name: "shift by nothing", shift: true, notes: []uint8{1, 2, 3, 4}, lines: 0, want: []uint8{1, 2, 3, 4}},
+		{name: "shift within a region", shift: true, notes: []uint8{1, 2, 3, 4, 5}, region: PatternRegion{Line: 1, Lines: 3}, lines: 1, want: []uint8{1, 0, 2, 3, 5}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testPatternData(max(test.tracks, 1), test.notes...)
+			if test.shift {
+				data.Shift(test.region, test.lines)
+			} else {
+				data.Rotate(test.region, test.lines)
+			}
+			if got := cellNotes(data); !slices.Equal(got, test.want) {
+				t.Errorf("got notes %v, want %v", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestCopyPaste(t *testing.T) {
+
+	source := testPatternData(2, 1, 2, 0, 4)
+
+	tests := []struct {
+		name    string
+		clip    PatternClip
+		track   int
+		line    int
+		mix     bool
+		want    []uint8
+		wantErr error
+	}{
+		{name: "whole clip", clip: source.Copy(PatternRegion{}), track: 0, line: 0, want: []uint8{1, 2, 9, 0, 4, 9, 9, 9, 9}},
+		{name: "clipped to the pattern", clip: source.Copy(PatternRegion{}), track: 2, line: 2, want: []uint8{9, 9, 9, 9, 9, 9, 9, 9, 1}},
+		{name: "negative position", clip: source.Copy(PatternRegion{}), track: -1, line: -1, want: []uint8{4, 9, 9, 9, 9, 9, 9, 9, 9}},
+		{name: "mixed", clip: source.Copy(PatternRegion{}), track: 1, line: 1, mix: true, want: []uint8{9, 9, 9, 9, 1, 2, 9, 9, 4}},
+		{name: "copied region", clip: source.Copy(PatternRegion{Track: 1, Tracks: 1}), track: 0, line: 1, want: []uint8{9, 9, 9, 2, 9, 9, 4, 9, 9}},
+		{name: "data too short", clip: PatternClip{Tracks: 2, Lines: 2, Data: make([]SunvoxPatternNoteData, 3)}, wantErr: ErrorInvalidClip, want: []uint8{9, 9, 9, 9, 9, 9, 9, 9, 9}},
+		{name: "negative size", clip: PatternClip{Tracks: -1, Lines: 2}, wantErr: ErrorInvalidClip, want: []uint8{9, 9, 9, 9, 9, 9, 9, 9, 9}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testPatternData(3, 9, 9, 9, 9, 9, 9, 9, 9, 9)
+			if err := data.Paste(test.clip, test.track, test.line, test.mix); !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if got := cellNotes(data); !slices.Equal(got, test.want) {
+				t.Errorf("got notes %v, want %v", got, test.want)
+			}
+		})
+	}
+
+}
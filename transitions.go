@@ -0,0 +1,338 @@
+package sunvoxgo
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+type quantizeMode int
+
+const (
+	quantizeNow quantizeMode = iota
+	quantizeLines
+	quantizeBeats
+	quantizeBars
+	quantizePatternEnd
+)
+
+// Quantize indicates which musical boundary a transition waits for before it's executed (see SunvoxChannel.ScheduleTransition()).
+// Quantize values are created through QuantizeLines(), QuantizeBeats(), QuantizeBars() and QuantizePatternEnd();
+// the zero value is QuantizeNow.
+type Quantize struct {
+	mode  quantizeMode
+	count float64
+}
+
+// QuantizeNow executes transitions immediately.
+var QuantizeNow = Quantize{}
+
+// QuantizeLines executes transitions on the next line that is a multiple of lineCount (so QuantizeLines(16) waits for
+// line 16, 32, 48, etc).
+func QuantizeLines(lineCount int) Quantize {
+	return Quantize{mode: quantizeLines, count: float64(max(lineCount, 1))}
+}
+
+// QuantizeBeats executes transitions at the start of the next beat that is a multiple of beatCount, counting from the
+// beginning of the song. The length of a beat depends on the song's TPL (see SunvoxChannel.LinesPerBeat()).
+func QuantizeBeats(beatCount int) Quantize {
+	return Quantize{mode: quantizeBeats, count: float64(max(beatCount, 1))}
+}
+
+// QuantizeBars executes transitions at the start of the next bar that is a multiple of barCount, counting from the
+// beginning of the song. The length of a bar depends on the SunvoxChannel's time signature (see SunvoxChannel.SetTimeSignature()).
+func QuantizeBars(barCount int) Quantize {
+	return Quantize{mode: quantizeBars, count: float64(max(barCount, 1))}
+}
+
+// QuantizePatternEnd executes transitions at the end of the pattern being played (or, if several are being played, the
+// first one to end). If no pattern is being played, the transition waits for the start of the next pattern instead.
+func QuantizePatternEnd() Quantize {
+	return Quantize{mode: quantizePatternEnd}
+}
+
+// nextBoundary returns the position (in lines) of the next boundary for the Quantize value, starting from the given position.
+func (q Quantize) nextBoundary(channel *SunvoxChannel, position float64) float64 {
+
+	step := 0.0
+
+	switch q.mode {
+	case quantizeLines:
+		step = q.count
+	case quantizeBeats:
+		step = q.count * float64(channel.LinesPerBeat())
+	case quantizeBars:
+		step = q.count * channel.LinesPerBar()
+	case quantizePatternEnd:
+		return channel.nextPatternBoundary(position)
+	}
+
+	if step <= 0 {
+		return position
+	}
+
+	// The small offset allows a position that's just been rounded down onto a boundary to count as being on it
+	return math.Ceil(position/step-1e-6) * step
+
+}
+
+// TimeSignature represents the time signature of a song, which is used to determine the length of a bar.
+// The zero value is treated as 4/4.
+type TimeSignature struct {
+	Beats int // The number of beats in a bar (the top number)
+	Unit  int // The note value that represents a beat (the bottom number)
+}
+
+// BeatsPerBar returns the length of a bar in the TimeSignature, in Sunvox beats (quarter notes); for example, a bar in
+// 6/8 time is 3 beats long.
+func (t TimeSignature) BeatsPerBar() float64 {
+	if t.Beats <= 0 || t.Unit <= 0 {
+		return 4
+	}
+	return float64(t.Beats) * 4 / float64(t.Unit)
+}
+
+// SetTimeSignature sets the time signature used for the song in the SunvoxChannel; by default, it's 4/4.
+// Sunvox projects don't store a time signature, so this is only used to determine bar lengths, as for QuantizeBars().
+func (s *SunvoxChannel) SetTimeSignature(timeSignature TimeSignature) {
+	s.timeSignatureMutex.Lock()
+	defer s.timeSignatureMutex.Unlock()
+	s.timeSignature = timeSignature
+}
+
+// TimeSignature returns the time signature used for the song in the SunvoxChannel.
+func (s *SunvoxChannel) TimeSignature() TimeSignature {
+	s.timeSignatureMutex.Lock()
+	defer s.timeSignatureMutex.Unlock()
+	if s.timeSignature.Beats <= 0 || s.timeSignature.Unit <= 0 {
+		return TimeSignature{Beats: 4, Unit: 4}
+	}
+	return s.timeSignature
+}
+
+// LinesPerBar returns the number of lines in each bar of the song in the SunvoxChannel, according to its time signature
+// and TPL. This can be fractional.
+func (s *SunvoxChannel) LinesPerBar() float64 {
+	return s.TimeSignature().BeatsPerBar() * float64(s.LinesPerBeat())
+}
+
+// nextPatternBoundary returns the end of the first pattern being played at the given position to end, or the start of
+// the next pattern if none are being played. If there are no more patterns, the end of the song is returned.
+func (s *SunvoxChannel) nextPatternBoundary(position float64) float64 {
+
+	boundary := math.Inf(1)
+	nextStart := math.Inf(1)
+
+	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+		x := float64(pattern.X())
+		x2 := float64(pattern.X2())
+		if x <= position && position < x2 {
+			boundary = min(boundary, x2)
+		} else if x > position {
+			nextStart = min(nextStart, x)
+		}
+		return true
+	})
+
+	if math.IsInf(boundary, 1) {
+		boundary = nextStart
+	}
+
+	if math.IsInf(boundary, 1) {
+		boundary = float64(s.LengthInLines())
+	}
+
+	return boundary
+
+}
+
+type transition struct {
+	target   float64 // The position (in lines) the transition's action should happen at
+	position float64 // The position of the playhead when the transition was last checked
+	action   func() error
+	future   *Future
+	timer    *time.Timer
+}
+
+// ScheduleTransition schedules the given action to be executed at the next boundary indicated by quantize (for example,
+// the next bar with QuantizeBars(1)), returning a Future that resolves with the action's error once it's been executed.
+// The Future can be used to cancel the transition before then. This is useful for adaptive music, where a change of
+// section (seeking, switching loop regions, unmuting layers, etc) should happen in time with the music.
+//
+// Sunvox's audio engine renders audio ahead of what can be heard, so the action is executed early by the engine's latency
+// (see SunvoxEngine.SetLatency()) so that its effect is heard at the boundary. The action is executed with the audio
+// engine paused, so several changes made within it take effect together.
+//
+// Transitions are checked by the engine's poller (see SunvoxEngine.SetUpdateMode()). In UpdateModeGoroutine, the
+// action is executed on a timer's goroutine at the exact time; in UpdateModeManual, it's executed by
+// SunvoxEngine.Update() once the time has come, so it's only as precise as Update() is frequent.
+//
+// If the SunvoxChannel isn't playing (or quantize is QuantizeNow), the action is executed immediately. Pending transitions
+// wait while playback is stopped or paused. If the playhead moves backwards before the boundary is reached (as when
+// the song or a loop region loops), the action is executed immediately, as the loop point is considered a boundary as well.
+func (s *SunvoxChannel) ScheduleTransition(quantize Quantize, action func() error) *Future {
+
+	position := s.CurrentPosition()
+
+	t := &transition{
+		target:   quantize.nextBoundary(s, position),
+		position: position,
+		action:   action,
+		future:   newFuture(),
+	}
+
+	if quantize.mode == quantizeNow || s.State() != StatePlaying {
+		s.runTransition(t)
+		return t.future
+	}
+
+	s.transitionMutex.Lock()
+	s.transitions = append(s.transitions, t)
+	s.transitionMutex.Unlock()
+
+	engine.startPolling()
+
+	// Check the transition immediately in case its time is before the next poll
+	s.updateTransitions()
+
+	return t.future
+
+}
+
+// CancelTransitions cancels all of the SunvoxChannel's pending transitions.
+func (s *SunvoxChannel) CancelTransitions() {
+	s.transitionMutex.Lock()
+	transitions := s.transitions
+	s.transitions = nil
+	s.transitionMutex.Unlock()
+
+	for _, t := range transitions {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		t.future.Cancel()
+	}
+}
+
+// updateTransitions checks the SunvoxChannel's pending transitions, executing those whose time has come (or, in
+// UpdateModeGoroutine, starting timers for those that will come before the next poll).
+func (s *SunvoxChannel) updateTransitions() {
+
+	s.transitionMutex.Lock()
+
+	if len(s.transitions) == 0 {
+		s.transitionMutex.Unlock()
+		return
+	}
+
+	// Transitions wait if the tempo can't be read, as the time until their boundaries can't be determined
+	lpm := float64(s.LPM())
+	playing := s.State() == StatePlaying && lpm > 0 && !math.IsInf(lpm, 0)
+	position := s.CurrentPosition()
+	secondsPerLine := 60 / lpm
+	latency := engine.Latency()
+	pollResolution := engine.PollResolution()
+	manual := engine.UpdateMode() == UpdateModeManual
+
+	due := []*transition{}
+
+	s.transitions = slices.DeleteFunc(s.transitions, func(t *transition) bool {
+
+		// Canceled transitions are dropped
+		if t.future.state.Load() != futurePending {
+			return true
+		}
+
+		if !playing || t.timer != nil {
+			return false
+		}
+
+		wait := time.Duration(0)
+		if position >= t.position {
+			wait = time.Duration((t.target-position)*secondsPerLine*float64(time.Second)) - latency
+		}
+		t.position = position
+
+		if wait <= 0 {
+			due = append(due, t)
+			return true
+		}
+
+		if !manual && wait <= pollResolution {
+			t.timer = time.AfterFunc(wait, func() {
+				s.transitionMutex.Lock()
+				s.transitions = slices.DeleteFunc(s.transitions, func(other *transition) bool { return other == t })
+				s.transitionMutex.Unlock()
+				s.runTransition(t)
+			})
+		}
+
+		return false
+
+	})
+
+	s.transitionMutex.Unlock()
+
+	for _, t := range due {
+		s.runTransition(t)
+	}
+
+}
+
+// runTransition executes the transition's action (with the audio engine paused), unless it has been canceled.
+func (s *SunvoxChannel) runTransition(t *transition) {
+	if !t.future.start() {
+		return
+	}
+	s.pauseEngine()
+	err := t.action()
+	s.resumeEngine()
+	t.future.resolve(err)
+}
+
+// closeTransitions resolves all of the SunvoxChannel's pending transitions with ErrorChannelClosed.
+func (s *SunvoxChannel) closeTransitions() {
+	s.transitionMutex.Lock()
+	transitions := s.transitions
+	s.transitions = nil
+	s.transitionMutex.Unlock()
+
+	for _, t := range transitions {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		if t.future.start() {
+			t.future.resolve(ErrorChannelClosed)
+		}
+	}
+}
+
+// SetLatency sets the engine's audio latency; that is, how far ahead of what can be heard Sunvox's audio engine renders
+// audio. It's used to execute transitions early enough that they're heard on time (see SunvoxChannel.ScheduleTransition()).
+// If latency is less than 0, it's estimated from the audio buffer size the engine was initialized with (the default).
+func (e *SunvoxEngine) SetLatency(latency time.Duration) {
+	e.latency.Store(int64(latency))
+}
+
+// Latency returns the engine's audio latency. See SetLatency() for more information.
+func (e *SunvoxEngine) Latency() time.Duration {
+
+	if latency := time.Duration(e.latency.Load()); latency >= 0 {
+		return latency
+	}
+
+	bufferSize := e.bufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1024 // Sunvox's default buffer size
+	}
+
+	sampleRate := 44100
+	if e.Initialized {
+		if sr := int(getSampleRate()); sr > 0 {
+			sampleRate = sr
+		}
+	}
+
+	return time.Duration(bufferSize) * time.Second / time.Duration(sampleRate)
+
+}
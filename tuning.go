@@ -0,0 +1,446 @@
+package sunvoxgo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrorNoteNotMapped = errors.New("error: the note isn't mapped to a pitch by the tuning")
+
+const (
+	// PitchC0 is the Sunvox pitch value of C0 (NoteMin), as used with NoteCommandSetPitch. Pitches below C0 have
+	// higher values, down to PitchLowest.
+	PitchC0 = 0x7800
+	// PitchLowest is the Sunvox pitch value of the lowest pitch, as used with NoteCommandSetPitch (the largest value the
+	// controller value column can hold).
+	PitchLowest = 0xFFFF
+	// PitchHighest is the Sunvox pitch value of the highest pitch, as used with NoteCommandSetPitch.
+	PitchHighest = 0
+	// PitchSemitone is how much the Sunvox pitch value changes by for each semitone (lower values are higher pitches).
+	PitchSemitone = 0x100
+)
+
+// pitchFrequencyC0 is the frequency in Hz that Sunvox plays the pitch value PitchC0 at, as used by its
+// SV_PITCH_TO_FREQUENCY() and SV_FREQUENCY_TO_PITCH() macros. It's slightly lower than C0 with A4 at 440 Hz (about
+// 16.3516 Hz), which is what Note.Frequency() gives.
+const pitchFrequencyC0 = 16.333984375
+
+// midiOffset is the difference between MIDI note numbers and Sunvox note values (MIDI note 69 is A4, which is 58 in Sunvox).
+const midiOffset = 11
+
+// FrequencyToPitch converts the given frequency in Hz to a Sunvox pitch value, as used with NoteCommandSetPitch (in the
+// controller value column), in the same way as Sunvox's SV_FREQUENCY_TO_PITCH() macro. Modules with their finetune or
+// relative note changed will be shifted by those amounts. Frequencies below C0 are given values above PitchC0; frequencies
+// outside of the range of pitch values (from PitchLowest to PitchHighest) are clamped to it.
+func FrequencyToPitch(hz float64) int {
+	if hz <= 0 {
+		return PitchLowest
+	}
+	semitones := 12 * math.Log2(hz/pitchFrequencyC0)
+	return int(min(max(math.Round(PitchC0-semitones*PitchSemitone), PitchHighest), PitchLowest))
+}
+
+// PitchToFrequency converts the given Sunvox pitch value (as used with NoteCommandSetPitch) to a frequency in Hz, in the
+// same way as Sunvox's SV_PITCH_TO_FREQUENCY() macro.
+func PitchToFrequency(pitch int) float64 {
+	return pitchFrequencyC0 * math.Pow(2, float64(PitchC0-pitch)/PitchSemitone/12)
+}
+
+// Pitch returns the Note's Sunvox pitch value, as used with NoteCommandSetPitch. If the Note isn't a playable note,
+// PitchLowest is returned.
+func (n Note) Pitch() int {
+	if !n.IsValid() {
+		return PitchLowest
+	}
+	return PitchC0 - (int(n)-1)*PitchSemitone
+}
+
+// KeyboardMapping indicates how notes are mapped onto the degrees of a Tuning, like a Scala .kbm file.
+type KeyboardMapping struct {
+	// Map holds the scale degree each note in a repeating group of notes plays, starting from the Middle note; a value
+	// of -1 leaves the note unmapped. If it's empty, every note plays the next scale degree from the last one.
+	Map []int
+
+	First  Note // The lowest note that's mapped
+	Last   Note // The highest note that's mapped
+	Middle Note // The note that plays the first degree (the root) of the scale
+
+	Reference          Note    // The note the ReferenceFrequency is given for
+	ReferenceFrequency float64 // The frequency the Reference note plays at, in Hz
+
+	// OctaveDegree is the scale degree that the mapping moves up by each time Map repeats; if it's 0, it's the number of
+	// steps in the scale (so the mapping moves up by the scale's period).
+	OctaveDegree int
+}
+
+// DefaultKeyboardMapping returns the keyboard mapping used by Scala when no .kbm file is given: every note is mapped to
+// the next scale degree, with the root of the scale on C4 (49), and A4 (58) at 440 Hz.
+func DefaultKeyboardMapping() KeyboardMapping {
+	return KeyboardMapping{
+		First:              NoteMin,
+		Last:               NoteMax,
+		Middle:             49,
+		Reference:          NoteA4,
+		ReferenceFrequency: 440,
+	}
+}
+
+// Tuning represents a musical tuning (like a Scala .scl file along with a .kbm file), which can be used to play notes at
+// pitches other than those of twelve-tone equal temperament, through Instrument.Tuning or SunvoxChannel.ApplyTuning().
+type Tuning struct {
+	Description string
+
+	// Steps holds the pitch of each degree of the scale after the root, in cents (hundredths of a semitone) above the
+	// root, in ascending order. The last step is the scale's period (usually an octave, or 1200 cents), after which the
+	// scale repeats.
+	Steps []float64
+
+	Mapping KeyboardMapping
+}
+
+// NewTuning returns a Tuning with the given steps (in cents above the root, ending with the scale's period; see
+// Tuning.Steps) and the default keyboard mapping (see DefaultKeyboardMapping()).
+func NewTuning(steps []float64) *Tuning {
+	return &Tuning{
+		Steps:   steps,
+		Mapping: DefaultKeyboardMapping(),
+	}
+}
+
+// NewEqualTuning returns a Tuning that divides the given period (in cents, so 1200 for an octave) into the given number
+// of equal steps, with the default keyboard mapping. For example, NewEqualTuning(24, 1200) gives quarter tones.
+func NewEqualTuning(steps int, period float64) *Tuning {
+	tuning := NewTuning(make([]float64, max(steps, 1)))
+	tuning.Description = fmt.Sprintf("%d equal divisions of %g cents", max(steps, 1), period)
+	for i := range tuning.Steps {
+		tuning.Steps[i] = period * float64(i+1) / float64(len(tuning.Steps))
+	}
+	return tuning
+}
+
+// NewTuningFromScala creates a Tuning from the contents of a Scala scale (.scl) file, along with those of a Scala
+// keyboard mapping (.kbm) file. If kbm is nil or empty, the default keyboard mapping is used (see DefaultKeyboardMapping()).
+// Notes are mapped from MIDI note numbers to Sunvox notes so that they play the same notes (MIDI note 60, middle C, is C4,
+// or 49).
+func NewTuningFromScala(scl, kbm []byte) (*Tuning, error) {
+
+	lines := scalaLines(scl)
+
+	if len(lines) < 2 {
+		return nil, errors.New("error parsing Scala scale; the file is missing its description or note count")
+	}
+
+	tuning := NewTuning(nil)
+	tuning.Description = strings.TrimSpace(lines[0])
+
+	count, err := strconv.Atoi(scalaValue(lines[1]))
+	if err != nil || count < 0 {
+		return nil, errors.New(fmt.Sprintf("error parsing Scala scale; \"%s\" isn't a valid note count", strings.TrimSpace(lines[1])))
+	}
+
+	if len(lines)-2 < count {
+		return nil, errors.New(fmt.Sprintf("error parsing Scala scale; the file only has %d of its %d notes", len(lines)-2, count))
+	}
+
+	for _, line := range lines[2 : count+2] {
+		cents, err := parseScalaPitch(scalaValue(line))
+		if err != nil {
+			return nil, err
+		}
+		tuning.Steps = append(tuning.Steps, cents)
+	}
+
+	if count == 0 {
+		// A scale of only its root repeats at every step
+		tuning.Steps = []float64{0}
+	}
+
+	if len(kbm) > 0 {
+		if err := tuning.parseKeyboardMapping(kbm); err != nil {
+			return nil, err
+		}
+	}
+
+	return tuning, nil
+
+}
+
+// LoadTuningFromFS loads a Tuning from the Scala scale (.scl) and keyboard mapping (.kbm) files of the given filenames
+// from the given file system. If kbmFilename is empty, the default keyboard mapping is used. See NewTuningFromScala() for
+// more information.
+func LoadTuningFromFS(fileSys fs.FS, sclFilename, kbmFilename string) (*Tuning, error) {
+
+	scl, err := fs.ReadFile(fileSys, sclFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	var kbm []byte
+
+	if kbmFilename != "" {
+		kbm, err = fs.ReadFile(fileSys, kbmFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewTuningFromScala(scl, kbm)
+
+}
+
+// Frequency returns the frequency in Hz the given note plays at in the Tuning, and whether the note is mapped to a pitch.
+func (t *Tuning) Frequency(note Note) (float64, bool) {
+
+	degree, ok := t.degree(note)
+	if !ok || len(t.Steps) == 0 {
+		return 0, false
+	}
+
+	reference, ok := t.degree(t.Mapping.Reference)
+	if !ok {
+		// If the reference note isn't mapped, the root of the scale is treated as being on it
+		reference = 0
+	}
+
+	cents := t.degreeCents(degree) - t.degreeCents(reference)
+
+	return t.Mapping.ReferenceFrequency * math.Pow(2, cents/1200), true
+
+}
+
+// Pitch returns the Sunvox pitch value (as used with NoteCommandSetPitch) of the given note in the Tuning, and whether
+// the note is mapped to a pitch.
+func (t *Tuning) Pitch(note Note) (int, bool) {
+	frequency, ok := t.Frequency(note)
+	if !ok {
+		return PitchLowest, false
+	}
+	return FrequencyToPitch(frequency), true
+}
+
+// degree returns the scale degree the given note plays, and whether it's mapped.
+func (t *Tuning) degree(note Note) (int, bool) {
+
+	mapping := t.Mapping
+
+	if !note.IsValid() || note < mapping.First || note > mapping.Last {
+		return 0, false
+	}
+
+	offset := int(note) - int(mapping.Middle)
+
+	if len(mapping.Map) == 0 {
+		return offset, true
+	}
+
+	octaveDegree := mapping.OctaveDegree
+	if octaveDegree <= 0 {
+		octaveDegree = len(t.Steps)
+	}
+
+	octave := floorDiv(offset, len(mapping.Map))
+	degree := mapping.Map[offset-octave*len(mapping.Map)]
+
+	if degree < 0 {
+		return 0, false
+	}
+
+	return degree + octave*octaveDegree, true
+
+}
+
+// degreeCents returns the pitch of the given scale degree in cents above the root. The Tuning must have steps.
+func (t *Tuning) degreeCents(degree int) float64 {
+	period := t.Steps[len(t.Steps)-1]
+	octave := floorDiv(degree, len(t.Steps))
+	step := degree - octave*len(t.Steps)
+	cents := float64(octave) * period
+	if step > 0 {
+		cents += t.Steps[step-1]
+	}
+	return cents
+}
+
+// parseKeyboardMapping parses the contents of a Scala keyboard mapping (.kbm) file into the Tuning's Mapping.
+func (t *Tuning) parseKeyboardMapping(kbm []byte) error {
+
+	lines := scalaLines(kbm)
+
+	if len(lines) < 7 {
+		return errors.New("error parsing Scala keyboard mapping; the file is missing its header values")
+	}
+
+	values := [7]float64{}
+
+	for i := range values {
+		value, err := strconv.ParseFloat(scalaValue(lines[i]), 64)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error parsing Scala keyboard mapping; \"%s\" isn't a valid value", strings.TrimSpace(lines[i])))
+		}
+		values[i] = value
+	}
+
+	// MIDI note numbers are converted to Sunvox notes, clamped to the range of playable notes
+	note := func(midi float64) Note {
+		return Note(min(max(int(midi)-midiOffset, int(NoteMin)), int(NoteMax)))
+	}
+
+	mapping := KeyboardMapping{
+		Map:                make([]int, max(int(values[0]), 0)),
+		First:              note(values[1]),
+		Last:               note(values[2]),
+		Middle:             note(values[3]),
+		Reference:          note(values[4]),
+		ReferenceFrequency: values[5],
+		OctaveDegree:       int(values[6]),
+	}
+
+	if mapping.ReferenceFrequency <= 0 {
+		return errors.New("error parsing Scala keyboard mapping; the reference frequency must be greater than 0")
+	}
+
+	for i := range mapping.Map {
+		// Keys that are missing from the end of the mapping are unmapped
+		mapping.Map[i] = -1
+		if i+7 >= len(lines) {
+			continue
+		}
+		value := scalaValue(lines[i+7])
+		if strings.EqualFold(value, "x") {
+			continue
+		}
+		degree, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error parsing Scala keyboard mapping; \"%s\" isn't a valid scale degree", value))
+		}
+		mapping.Map[i] = degree
+	}
+
+	t.Mapping = mapping
+
+	return nil
+
+}
+
+// scalaLines returns the lines of a Scala file, leaving out comments (lines starting with "!").
+func scalaLines(data []byte) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// scalaValue returns the value of a line of a Scala file, which is its first word; anything after it is ignored.
+func scalaValue(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// parseScalaPitch parses a pitch from a Scala scale, returning it in cents. Pitches with a period are in cents; others
+// are ratios (like "3/2") or whole numbers (like "2", for 2/1).
+func parseScalaPitch(value string) (float64, error) {
+
+	if strings.Contains(value, ".") {
+		cents, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("error parsing Scala scale; \"%s\" isn't a valid pitch in cents", value))
+		}
+		return cents, nil
+	}
+
+	numerator, denominator, found := strings.Cut(value, "/")
+	if !found {
+		denominator = "1"
+	}
+
+	n, err1 := strconv.ParseFloat(numerator, 64)
+	d, err2 := strconv.ParseFloat(denominator, 64)
+
+	if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return 0, errors.New(fmt.Sprintf("error parsing Scala scale; \"%s\" isn't a valid ratio", value))
+	}
+
+	return 1200 * math.Log2(n/d), nil
+
+}
+
+// ApplyTuning remaps the notes of the SunvoxPatternData to the pitches given by the Tuning, replacing each note with
+// NoteCommandSetPitch and its pitch (which plays the note at that pitch). As the pitch is set in the controller value
+// column, notes that have a controller / effect or value set are left as they are, as are notes that the Tuning doesn't
+// map. The number of notes remapped is returned.
+func (s SunvoxPatternData) ApplyTuning(tuning *Tuning) int {
+	return s.applyTuning(tuning, nil)
+}
+
+// applyTuning remaps the notes of the SunvoxPatternData to the Tuning's pitches, as in ApplyTuning(). If filter isn't
+// nil, only the notes it returns true for are remapped.
+func (s SunvoxPatternData) applyTuning(tuning *Tuning, filter func(noteData SunvoxPatternNoteData) bool) int {
+	defer s.editStep("Apply tuning")()
+	count := 0
+	for i, noteData := range s.Data {
+		if !Note(noteData.Note).IsValid() || noteData.Controller != 0 || noteData.ControllerValue != 0 {
+			continue
+		}
+		if filter != nil && !filter(noteData) {
+			continue
+		}
+		pitch, ok := tuning.Pitch(Note(noteData.Note))
+		if !ok {
+			continue
+		}
+		noteData.Note = NoteCommandSetPitch
+		noteData.ControllerValue = uint16(pitch)
+		s.setCell(i, noteData)
+		count++
+	}
+	return count
+}
+
+// ApplyTuning remaps the notes of all of the SunvoxChannel's patterns to the pitches given by the Tuning (see
+// SunvoxPatternData.ApplyTuning()). If any modules are given, only notes played on those modules are remapped.
+// The number of notes remapped is returned.
+func (s *SunvoxChannel) ApplyTuning(tuning *Tuning, modules ...*SunvoxModule) int {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var filter func(noteData SunvoxPatternNoteData) bool
+
+	if len(modules) > 0 {
+		moduleNumbers := map[uint16]bool{}
+		for _, module := range modules {
+			moduleNumbers[uint16(module.Index+1)] = true
+		}
+		filter = func(noteData SunvoxPatternNoteData) bool { return moduleNumbers[noteData.Module] }
+	}
+
+	s.BeginEditStep("Apply tuning")
+	defer s.EndEditStep()
+
+	count := 0
+
+	s.ForEachPattern(func(pattern *SunvoxPattern) bool {
+		if data, err := pattern.Data(); err == nil {
+			count += data.applyTuning(tuning, filter)
+		}
+		return true
+	})
+
+	return count
+
+}
@@ -0,0 +1,179 @@
+package sunvoxgo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFrequencyToPitch(t *testing.T) {
+
+	tests := []struct {
+		name string
+		hz   float64
+		want int
+	}{
+		{name: "reference", hz: 16.333984375, want: PitchC0},
+		{name: "octave above", hz: 16.333984375 * 2, want: PitchC0 - 12*PitchSemitone},
+		{name: "semitone above", hz: 16.333984375 * math.Pow(2, 1.0/12), want: PitchC0 - PitchSemitone},
+		{name: "below C0", hz: 16.333984375 / 2, want: PitchC0 + 12*PitchSemitone},
+		{name: "lowest", hz: 0.001, want: PitchLowest},
+		{name: "highest", hz: 1e9, want: PitchHighest},
+		{name: "zero", hz: 0, want: PitchLowest},
+		{name: "negative", hz: -100, want: PitchLowest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FrequencyToPitch(test.hz); got != test.want {
+				t.Errorf("FrequencyToPitch(%v) = %#x, want %#x", test.hz, got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestPitchToFrequency(t *testing.T) {
+
+	tests := []struct {
+		pitch int
+		want  float64
+	}{
+		{pitch: PitchC0, want: 16.333984375},
+		{pitch: PitchC0 - 12*PitchSemitone, want: 16.333984375 * 2},
+		{pitch: PitchC0 + 12*PitchSemitone, want: 16.333984375 / 2},
+		{pitch: NoteA4.Pitch(), want: 16.333984375 * math.Pow(2, 57.0/12)},
+	}
+
+	for _, test := range tests {
+		if got := PitchToFrequency(test.pitch); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("PitchToFrequency(%#x) = %v, want %v", test.pitch, got, test.want)
+		}
+	}
+
+	// Every pitch value converts back to itself
+	for pitch := PitchHighest; pitch <= PitchLowest; pitch += 0x7F {
+		if got := FrequencyToPitch(PitchToFrequency(pitch)); got != pitch {
+			t.Errorf("FrequencyToPitch(PitchToFrequency(%#x)) = %#x", pitch, got)
+		}
+	}
+
+}
+
+func TestNewTuningFromScala(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		scl       string
+		kbm       string
+		wantErr   bool
+		wantDesc  string
+		wantSteps []float64
+	}{
+		{
+			name:      "cents and ratios",
+			scl:       "! meantone.scl\n!\nQuarter-comma meantone\n 3\n!\n 193.157\n 5/4 ignored text\n 2\n",
+			wantDesc:  "Quarter-comma meantone",
+			wantSteps: []float64{193.157, 1200 * math.Log2(5.0/4), 1200},
+		},
+		{
+			name:      "windows line endings",
+			scl:       "Two steps\r\n2\r\n600.0\r\n2/1\r\n",
+			wantDesc:  "Two steps",
+			wantSteps: []float64{600, 1200},
+		},
+		{
+			name:      "only a root",
+			scl:       "Nothing\n0\n",
+			wantDesc:  "Nothing",
+			wantSteps: []float64{0},
+		},
+		{name: "missing note count", scl: "Description\n", wantErr: true},
+		{name: "invalid note count", scl: "Description\nmany\n", wantErr: true},
+		{name: "missing notes", scl: "Description\n3\n100.0\n", wantErr: true},
+		{name: "invalid ratio", scl: "Description\n1\n3/0\n", wantErr: true},
+		{name: "invalid cents", scl: "Description\n1\n1.2.3\n", wantErr: true},
+		{name: "kbm missing values", scl: "Description\n1\n2/1\n", kbm: "12\n0\n127\n", wantErr: true},
+		{name: "kbm invalid reference", scl: "Description\n1\n2/1\n", kbm: "0\n0\n127\n60\n69\n0\n1\n", wantErr: true},
+		{name: "kbm invalid degree", scl: "Description\n1\n2/1\n", kbm: "1\n0\n127\n60\n69\n440.0\n1\ny\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			tuning, err := NewTuningFromScala([]byte(test.scl), []byte(test.kbm))
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tuning.Description != test.wantDesc {
+				t.Errorf("got description %q, want %q", tuning.Description, test.wantDesc)
+			}
+
+			if len(tuning.Steps) != len(test.wantSteps) {
+				t.Fatalf("got steps %v, want %v", tuning.Steps, test.wantSteps)
+			}
+
+			for i := range tuning.Steps {
+				if math.Abs(tuning.Steps[i]-test.wantSteps[i]) > 1e-9 {
+					t.Errorf("got steps %v, want %v", tuning.Steps, test.wantSteps)
+					break
+				}
+			}
+
+		})
+	}
+
+}
+
+func TestTuningFrequency(t *testing.T) {
+
+	// A keyboard mapping of two keys repeating from MIDI note 60 (C4, or 49), with the first playing the next degree of the
+	// scale and the second unmapped, from MIDI note 48 (C3, or 37) up to 72 (C5, or 61); C4 plays the root at 261.6 Hz
+	kbm := "2\n48\n72\n60\n60\n261.6\n1\n0\nx\n"
+
+	tuned, err := NewTuningFromScala([]byte("Whole tones\n6\n200.0\n400.0\n600.0\n800.0\n1000.0\n2/1\n"), []byte(kbm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tuning *Tuning
+		note   Note
+		want   float64
+		wantOk bool
+	}{
+		{name: "equal temperament A4", tuning: NewEqualTuning(12, 1200), note: NoteA4, want: 440, wantOk: true},
+		{name: "equal temperament C4", tuning: NewEqualTuning(12, 1200), note: 49, want: Note(49).Frequency(), wantOk: true},
+		{name: "equal temperament lowest", tuning: NewEqualTuning(12, 1200), note: NoteMin, want: NoteMin.Frequency(), wantOk: true},
+		{name: "quarter tones", tuning: NewEqualTuning(24, 1200), note: NoteA4 + 2, want: 440 * math.Pow(2, 1.0/12), wantOk: true},
+		{name: "mapped root", tuning: tuned, note: 49, want: 261.6, wantOk: true},
+		{name: "unmapped key", tuning: tuned, note: 50, wantOk: false},
+		{name: "next degree", tuning: tuned, note: 51, want: 261.6 * math.Pow(2, 200.0/1200), wantOk: true},
+		{name: "degree below root", tuning: tuned, note: 47, want: 261.6 * math.Pow(2, -200.0/1200), wantOk: true},
+		{name: "below mapped range", tuning: tuned, note: 36, wantOk: false},
+		{name: "above mapped range", tuning: tuned, note: 62, wantOk: false},
+		{name: "not a note", tuning: tuned, note: NoteNone, wantOk: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.tuning.Frequency(test.note)
+			if ok != test.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, test.wantOk)
+			}
+			if ok && math.Abs(got-test.want) > 1e-6 {
+				t.Errorf("got %v Hz, want %v Hz", got, test.want)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,517 @@
+package sunvoxgo
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Curve is a function that shapes a fade or tween, mapping its progress (from 0 to 1) to how far along the value
+// should be (generally also from 0 to 1).
+type Curve func(t float32) float32
+
+// CurveLinear changes the value at a constant rate.
+func CurveLinear(t float32) float32 {
+	return t
+}
+
+// CurveEqualPower follows a quarter sine wave, so that two sounds crossfaded with it keep roughly the same combined
+// loudness throughout the crossfade (rather than dipping in the middle, as with CurveLinear).
+func CurveEqualPower(t float32) float32 {
+	return float32(math.Sin(float64(t) * math.Pi / 2))
+}
+
+// CurveEaseIn starts slowly and speeds up (quadratically).
+func CurveEaseIn(t float32) float32 {
+	return t * t
+}
+
+// CurveEaseOut starts quickly and slows down (quadratically).
+func CurveEaseOut(t float32) float32 {
+	return 1 - (1-t)*(1-t)
+}
+
+// CurveEaseInOut starts slowly, speeds up, and then slows down again (quadratically).
+func CurveEaseInOut(t float32) float32 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return 1 - (-2*t+2)*(-2*t+2)/2
+}
+
+// CurveExponentialIn starts very slowly and speeds up sharply towards the end.
+func CurveExponentialIn(t float32) float32 {
+	if t <= 0 {
+		return 0
+	}
+	return float32(math.Pow(2, 10*float64(t)-10))
+}
+
+// CurveExponentialOut starts very quickly and slows down sharply.
+func CurveExponentialOut(t float32) float32 {
+	if t >= 1 {
+		return 1
+	}
+	return 1 - float32(math.Pow(2, -10*float64(t)))
+}
+
+// CurveSCurve eases in and out smoothly (following the smoothstep function).
+func CurveSCurve(t float32) float32 {
+	return t * t * (3 - 2*t)
+}
+
+// decibelFloor is the level (in decibels) treated as silence when tweening in decibels.
+const decibelFloor = -60.0
+
+type tweenState int
+
+const (
+	tweenIdle tweenState = iota
+	tweenRunning
+	tweenCompleting // The Tween has reached its end, but hasn't been completed by its TweenManager yet
+	tweenFinished
+	tweenCanceled
+)
+
+// Tween changes a value (like a channel's volume or a module's controller) over time. Tweens are created through
+// NewTween() or one of the Tween* functions of SunvoxChannel and SunvoxModule, optionally configured through their With*
+// functions, and then started through Start() (or added to a TweenManager).
+type Tween struct {
+	from       float64
+	to         float64
+	hasFrom    bool
+	duration   time.Duration
+	delay      time.Duration
+	curve      Curve
+	decibels   bool
+	get        func() float64
+	set        func(value float64)
+	onComplete func()
+	next       *Tween
+
+	mutex   sync.Mutex
+	state   tweenState
+	elapsed time.Duration
+	begun   bool
+	start   float64
+	value   float64
+	done    chan struct{}
+}
+
+// NewTween creates a Tween that changes a value from the given starting value to the given ending value over the given
+// duration, calling set with each new value.
+func NewTween(from, to float64, duration time.Duration, set func(value float64)) *Tween {
+	return &Tween{
+		from:     from,
+		to:       to,
+		hasFrom:  true,
+		duration: duration,
+		set:      set,
+		done:     make(chan struct{}),
+	}
+}
+
+// newTargetTween creates a Tween that changes a value from whatever it is when the Tween begins to the given ending value.
+func newTargetTween(get func() float64, set func(value float64), to float64, duration time.Duration) *Tween {
+	return &Tween{
+		to:       to,
+		duration: duration,
+		get:      get,
+		set:      set,
+		done:     make(chan struct{}),
+	}
+}
+
+// WithFrom sets the value the Tween starts from. By default, Tweens created for a target (like SunvoxChannel.TweenVolume())
+// start from the target's value when the Tween begins (after its delay).
+func (t *Tween) WithFrom(from float64) *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.from = from
+	t.hasFrom = true
+	return t
+}
+
+// WithCurve sets the easing Curve used for the Tween (like CurveEaseInOut); by default, it's linear.
+func (t *Tween) WithCurve(curve Curve) *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.curve = curve
+	return t
+}
+
+// WithDelay sets how long the Tween waits after being started before it begins.
+func (t *Tween) WithDelay(delay time.Duration) *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.delay = delay
+	return t
+}
+
+// WithDecibels sets the Tween to interpolate its value linearly in decibels rather than in amplitude, which sounds more
+// even for volume changes (a linear fade out in amplitude seems to drop off suddenly at the end). Values are treated as
+// amplitudes (with 1 being 0 dB), and anything at or below -60 dB is treated as silence (0).
+func (t *Tween) WithDecibels() *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.decibels = true
+	return t
+}
+
+// WithOnComplete sets a function to be called once the Tween finishes (but not if it's canceled).
+func (t *Tween) WithOnComplete(onComplete func()) *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.onComplete = onComplete
+	return t
+}
+
+// Then sets the given Tween to be started once this one finishes, returning the given Tween so that calls can be chained
+// to create a sequence (as in a.Then(b).Then(c)). Only the first Tween in a sequence should be started.
+func (t *Tween) Then(next *Tween) *Tween {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.next = next
+	return next
+}
+
+// NewTweenSequence chains the given Tweens so that each is started once the previous one finishes (see Then()), and
+// returns the first one, which can be started to start the sequence.
+func NewTweenSequence(tweens ...*Tween) *Tween {
+	if len(tweens) == 0 {
+		return nil
+	}
+	for i := 0; i < len(tweens)-1; i++ {
+		tweens[i].Then(tweens[i+1])
+	}
+	return tweens[0]
+}
+
+// Start starts the Tween (restarting it if it has been run before) on the engine's TweenManager (see SunvoxEngine.Tweens()),
+// so that it runs by itself. Start returns the Tween.
+func (t *Tween) Start() *Tween {
+	engine.Tweens().Add(t)
+	return t
+}
+
+// Cancel stops the Tween wherever it is, along with any Tweens set to follow it through Then().
+func (t *Tween) Cancel() {
+	t.mutex.Lock()
+	next := t.next
+	if t.state != tweenCanceled && t.state != tweenCompleting && t.state != tweenFinished {
+		t.state = tweenCanceled
+		close(t.done)
+	}
+	t.mutex.Unlock()
+
+	if next != nil {
+		next.Cancel()
+	}
+}
+
+// IsRunning returns if the Tween has been started and hasn't finished or been canceled yet.
+func (t *Tween) IsRunning() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.state == tweenRunning
+}
+
+// Done returns a channel that is closed once the Tween finishes or is canceled.
+func (t *Tween) Done() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.done
+}
+
+// Value returns the Tween's current value.
+func (t *Tween) Value() float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.value
+}
+
+// reset readies the Tween to run from the beginning.
+func (t *Tween) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state == tweenFinished || t.state == tweenCanceled {
+		t.done = make(chan struct{})
+	}
+	t.state = tweenRunning
+	t.elapsed = 0
+	t.begun = false
+}
+
+// advance advances the Tween by dt, setting its value, and returns the value and whether the Tween is no longer running.
+// The Tween's get and set functions are called without holding its mutex, so they can call the Tween's functions
+// (like Value() or Cancel()) themselves.
+func (t *Tween) advance(dt time.Duration) (float64, bool) {
+
+	t.mutex.Lock()
+
+	if t.state != tweenRunning {
+		value := t.value
+		t.mutex.Unlock()
+		return value, true
+	}
+
+	t.elapsed += dt
+
+	if !t.begun {
+
+		if t.elapsed < t.delay {
+			value := t.value
+			t.mutex.Unlock()
+			return value, false
+		}
+
+		t.elapsed -= t.delay
+		t.begun = true
+
+		t.start = t.from
+
+		if !t.hasFrom && t.get != nil {
+
+			get := t.get
+			t.mutex.Unlock()
+			start := get()
+			t.mutex.Lock()
+
+			t.start = start
+
+			// The Tween could have been canceled while its starting value was retrieved
+			if t.state != tweenRunning {
+				value := t.value
+				t.mutex.Unlock()
+				return value, true
+			}
+
+		}
+
+	}
+
+	progress := 1.0
+	if t.duration > 0 {
+		progress = min(float64(t.elapsed)/float64(t.duration), 1)
+	}
+
+	t.value = t.interpolate(progress)
+
+	finished := progress >= 1
+	if finished {
+		t.state = tweenCompleting
+	}
+
+	value := t.value
+	set := t.set
+
+	t.mutex.Unlock()
+
+	if set != nil {
+		set(value)
+	}
+
+	return value, finished
+
+}
+
+// interpolate returns the Tween's value at the given progress.
+func (t *Tween) interpolate(progress float64) float64 {
+
+	amount := progress
+	if t.curve != nil {
+		amount = float64(t.curve(float32(progress)))
+	}
+
+	if !t.decibels {
+		return t.start + (t.to-t.start)*amount
+	}
+
+	toDecibels := func(v float64) float64 {
+		if v <= 0 {
+			return decibelFloor
+		}
+		return max(20*math.Log10(v), decibelFloor)
+	}
+
+	db := toDecibels(t.start) + (toDecibels(t.to)-toDecibels(t.start))*amount
+
+	if db <= decibelFloor {
+		return 0
+	}
+
+	return math.Pow(10, db/20)
+
+}
+
+// complete finishes the Tween if it has reached its end, calling its completion callback and starting the next Tween (if
+// there is one) on the given TweenManager. If the Tween was restarted or canceled since then, complete does nothing.
+func (t *Tween) complete(manager *TweenManager) {
+
+	t.mutex.Lock()
+	if t.state != tweenCompleting {
+		t.mutex.Unlock()
+		return
+	}
+	t.state = tweenFinished
+	onComplete := t.onComplete
+	next := t.next
+	close(t.done)
+	t.mutex.Unlock()
+
+	if onComplete != nil {
+		onComplete()
+	}
+
+	// A following Tween that was canceled by itself doesn't run
+	if next != nil {
+		next.mutex.Lock()
+		canceled := next.state == tweenCanceled
+		next.mutex.Unlock()
+		if !canceled {
+			manager.Add(next)
+		}
+	}
+
+}
+
+// TweenManager runs Tweens. The engine has its own TweenManager that runs by itself (see SunvoxEngine.Tweens()), but
+// TweenManagers created through NewTweenManager() run on their own clock, advancing only when Update() is called.
+type TweenManager struct {
+	mutex  sync.Mutex
+	tweens []*Tween
+	auto   bool
+}
+
+// NewTweenManager creates a TweenManager that advances its Tweens whenever Update() is called.
+func NewTweenManager() *TweenManager {
+	return &TweenManager{}
+}
+
+// Tweens returns the engine's TweenManager, which is updated by the engine (see SunvoxEngine.SetUpdateMode()).
+// Tween.Start() adds Tweens to it.
+func (e *SunvoxEngine) Tweens() *TweenManager {
+	return e.tweens
+}
+
+// Add starts the given Tween on the TweenManager (restarting it if it has been run before).
+func (m *TweenManager) Add(tween *Tween) {
+
+	tween.reset()
+
+	m.mutex.Lock()
+	if !slices.Contains(m.tweens, tween) {
+		m.tweens = append(m.tweens, tween)
+	}
+	m.mutex.Unlock()
+
+	if m.auto {
+		engine.addTask(m)
+	}
+
+}
+
+// Update advances all of the TweenManager's Tweens by dt, calling completion callbacks and starting following Tweens
+// for those that finish.
+func (m *TweenManager) Update(dt time.Duration) {
+
+	m.mutex.Lock()
+	tweens := slices.Clone(m.tweens)
+	m.mutex.Unlock()
+
+	for _, tween := range tweens {
+
+		if _, finished := tween.advance(dt); !finished {
+			continue
+		}
+
+		// The Tween could have been added again since it finished, in which case it stays
+		m.mutex.Lock()
+		m.tweens = slices.DeleteFunc(m.tweens, func(t *Tween) bool { return t == tween && !t.IsRunning() })
+		m.mutex.Unlock()
+
+		tween.complete(m)
+
+	}
+
+}
+
+// CancelAll cancels all of the TweenManager's Tweens.
+func (m *TweenManager) CancelAll() {
+	m.mutex.Lock()
+	tweens := m.tweens
+	m.tweens = nil
+	m.mutex.Unlock()
+
+	for _, tween := range tweens {
+		tween.Cancel()
+	}
+}
+
+// Len returns the number of Tweens running on the TweenManager.
+func (m *TweenManager) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.tweens)
+}
+
+func (m *TweenManager) step(dt float32) bool {
+	m.Update(secondsToDuration(dt))
+	// Once it's empty, the TweenManager stops being updated until a Tween is added to it again
+	return m.Len() == 0
+}
+
+// TweenVolume creates a Tween that changes the SunvoxChannel's volume to the given value (from 0 to 1) over the given
+// duration. Use Tween.Start() to start it.
+func (s *SunvoxChannel) TweenVolume(volume float32, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { v, _ := s.Volume(); return float64(v) },
+		func(v float64) { s.SetVolume(float32(v)) },
+		float64(volume), duration)
+}
+
+// TweenBPM creates a Tween that changes the BPM of the song in the SunvoxChannel to the given value over the given duration.
+// Use Tween.Start() to start it.
+func (s *SunvoxChannel) TweenBPM(bpm float32, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { return float64(s.BPM()) },
+		func(v float64) { s.SetBPM(float32(v)) },
+		float64(bpm), duration)
+}
+
+// TweenTPL creates a Tween that changes the TPL (ticks per line) of the song in the SunvoxChannel to the given value over the
+// given duration. Use Tween.Start() to start it.
+func (s *SunvoxChannel) TweenTPL(tpl int, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { return float64(s.TPL()) },
+		func(v float64) { s.SetTPL(int(math.Round(v))) },
+		float64(tpl), duration)
+}
+
+// TweenFinetune creates a Tween that changes the module's finetune value to the given value over the given duration.
+// Use Tween.Start() to start it.
+func (m *SunvoxModule) TweenFinetune(finetune int, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { return float64(int32(m.Finetune())) },
+		func(v float64) { m.setFinetune(int(math.Round(v))) },
+		float64(finetune), duration)
+}
+
+// TweenRelativeNote creates a Tween that changes the module's relative note value to the given value over the given
+// duration. Use Tween.Start() to start it.
+func (m *SunvoxModule) TweenRelativeNote(relativeNote int, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { return float64(int32(m.RelativeNote())) },
+		func(v float64) { m.setRelativeNote(int(math.Round(v))) },
+		float64(relativeNote), duration)
+}
+
+// TweenController creates a Tween that changes the numbered controller of the module to the given value over the given
+// duration. Use Tween.Start() to start it.
+func (m *SunvoxModule) TweenController(ctrlNum, value int, duration time.Duration) *Tween {
+	return newTargetTween(
+		func() float64 { v, _ := m.ControllerValue(ctrlNum); return float64(v) },
+		func(v float64) { m.setControllerValue(ctrlNum, int(math.Round(v))) },
+		float64(value), duration)
+}
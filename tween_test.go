@@ -0,0 +1,92 @@
+package sunvoxgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTweenCallbacksCanUseTween(t *testing.T) {
+
+	manager := NewTweenManager()
+
+	var tween *Tween
+	values := []float64{}
+
+	tween = NewTween(0, 1, 4*time.Second, func(value float64) {
+		values = append(values, tween.Value())
+		if value >= 0.5 {
+			tween.Cancel()
+		}
+	})
+
+	manager.Add(tween)
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 4; i++ {
+			manager.Update(time.Second)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the Tween's set function deadlocked calling the Tween's functions")
+	}
+
+	if len(values) != 2 || values[0] != 0.25 || values[1] != 0.5 {
+		t.Errorf("got values %v, want [0.25 0.5]", values)
+	}
+
+	if tween.IsRunning() {
+		t.Error("the Tween is still running after being canceled from its set function")
+	}
+
+}
+
+func TestTweenRestartedAsItFinishes(t *testing.T) {
+
+	manager := NewTweenManager()
+
+	var tween *Tween
+	restarted := false
+	completions := 0
+
+	tween = NewTween(0, 1, time.Second, func(value float64) {
+		// Restarting the Tween once it reaches its end, but before it's completed
+		if value >= 1 && !restarted {
+			restarted = true
+			manager.Add(tween)
+		}
+	}).WithOnComplete(func() { completions++ })
+
+	manager.Add(tween)
+	done := tween.Done()
+
+	manager.Update(time.Second)
+
+	if completions != 0 || !tween.IsRunning() || manager.Len() != 1 {
+		t.Fatalf("got %d completions, running %v, %d Tweens after restarting the Tween; want 0, true, 1", completions, tween.IsRunning(), manager.Len())
+	}
+
+	select {
+	case <-done:
+		t.Fatal("the restarted Tween's Done channel was closed")
+	default:
+	}
+
+	manager.Update(time.Second)
+
+	if completions != 1 || tween.IsRunning() || manager.Len() != 0 {
+		t.Errorf("got %d completions, running %v, %d Tweens after the Tween finished; want 1, false, 0", completions, tween.IsRunning(), manager.Len())
+	}
+
+	select {
+	case <-done:
+	default:
+		t.Error("the Tween's Done channel wasn't closed once it finished")
+	}
+
+}
@@ -0,0 +1,135 @@
+package sunvoxgo
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// UpdateMode indicates how the SunvoxEngine polls channels for playback events and updates running fades.
+type UpdateMode int
+
+const (
+	UpdateModeGoroutine UpdateMode = iota // Channels are polled and fades are updated automatically on a background goroutine (the default)
+	UpdateModeManual                      // Channels are polled and fades are updated only when SunvoxEngine.Update() is called
+)
+
+// engineTask is something that is updated by the engine each time it polls (like a running VolumeFade).
+type engineTask interface {
+	// step advances the task by dt seconds, returning true once the task is finished.
+	step(dt float32) bool
+}
+
+// SetUpdateMode sets how the engine polls channels for playback events and updates running fades.
+//
+// In UpdateModeGoroutine (the default), this happens on a background goroutine, so callbacks are called on that goroutine.
+// In UpdateModeManual, this only happens when SunvoxEngine.Update() is called, so callbacks are called on whichever goroutine
+// calls Update() (like a game's main thread). The same events are produced either way.
+func (e *SunvoxEngine) SetUpdateMode(mode UpdateMode) {
+
+	e.pollMutex.Lock()
+	e.updateMode.Store(int32(mode))
+	e.pollMutex.Unlock()
+
+	if mode == UpdateModeManual {
+		e.stopPolling()
+		e.resetTaskClock()
+	} else {
+		e.startPolling()
+	}
+
+}
+
+// UpdateMode returns how the engine polls channels for playback events and updates running fades.
+func (e *SunvoxEngine) UpdateMode() UpdateMode {
+	return UpdateMode(e.updateMode.Load())
+}
+
+// Update polls all channels for playback events, calling any callbacks for them, and updates any running fades, all on the
+// calling goroutine. It should be called regularly (e.g. once per frame in a game) when the engine's update mode is
+// UpdateModeManual; fades are advanced by the time elapsed since the previous call.
+// In UpdateModeGoroutine, Update does nothing, as this happens automatically.
+func (e *SunvoxEngine) Update() {
+	if e.UpdateMode() != UpdateModeManual {
+		return
+	}
+	e.poll(context.Background(), time.Now())
+}
+
+// addTask adds a task to be updated each time the engine polls.
+func (e *SunvoxEngine) addTask(task engineTask) {
+	e.taskMutex.Lock()
+	if !slices.Contains(e.tasks, task) {
+		e.tasks = append(e.tasks, task)
+	}
+	e.tasksAdded++
+	e.taskMutex.Unlock()
+
+	e.startPolling()
+}
+
+// removeTask removes a task from the engine.
+func (e *SunvoxEngine) removeTask(task engineTask) {
+	e.taskMutex.Lock()
+	defer e.taskMutex.Unlock()
+	if i := slices.Index(e.tasks, task); i >= 0 {
+		e.tasks = slices.Delete(e.tasks, i, i+1)
+	}
+}
+
+// hasTask returns if the task is currently being updated by the engine.
+func (e *SunvoxEngine) hasTask(task engineTask) bool {
+	e.taskMutex.Lock()
+	defer e.taskMutex.Unlock()
+	return slices.Contains(e.tasks, task)
+}
+
+// updateTasks advances all of the engine's tasks, removing the ones that have finished.
+func (e *SunvoxEngine) updateTasks(now time.Time) {
+
+	e.taskMutex.Lock()
+
+	dt := float32(0)
+	if !e.lastTaskUpdate.IsZero() {
+		dt = float32(now.Sub(e.lastTaskUpdate).Seconds())
+	}
+	e.lastTaskUpdate = now
+
+	tasks := append([]engineTask{}, e.tasks...)
+	added := e.tasksAdded
+
+	// Time doesn't accumulate while there's nothing to update, so newly added tasks don't jump ahead
+	if len(tasks) == 0 {
+		e.lastTaskUpdate = time.Time{}
+	}
+
+	e.taskMutex.Unlock()
+
+	for _, task := range tasks {
+		if task.step(dt) {
+			e.removeFinishedTask(task, added)
+		}
+	}
+
+}
+
+// resetTaskClock makes the next update of the engine's tasks advance them by nothing, as when updating starts again.
+func (e *SunvoxEngine) resetTaskClock() {
+	e.taskMutex.Lock()
+	e.lastTaskUpdate = time.Time{}
+	e.taskMutex.Unlock()
+}
+
+// removeFinishedTask removes a task that finished while the engine's tasks were being updated, unless any task was added
+// since added (the number of times addTask had been called) was read; the task could have been added again after it
+// finished (like a TweenManager that's been given another Tween), so it's left to be updated once more instead.
+func (e *SunvoxEngine) removeFinishedTask(task engineTask, added uint64) {
+	e.taskMutex.Lock()
+	defer e.taskMutex.Unlock()
+	if e.tasksAdded != added {
+		return
+	}
+	if i := slices.Index(e.tasks, task); i >= 0 {
+		e.tasks = slices.Delete(e.tasks, i, i+1)
+	}
+}
@@ -1,40 +1,26 @@
 package sunvoxgo
 
-// type FutureError struct {
-// 	errChan chan error
-// 	error
-// 	errorReturned bool
-// }
-
-// func newFutureError() *FutureError {
-// 	return &FutureError{
-// 		errChan: make(chan error, 1),
-// 	}
-// }
-
-// var ErrorFunctionHasNotReturned = errors.New("function has not returned")
-
-// func (e *FutureError) Error() error {
-
-// 	if len(e.errChan) > 0 {
-// 		e.error = <-e.errChan
-// 		e.errorReturned = true
-// 	}
-
-// 	if e.errorReturned {
-// 		return e.error
-// 	}
-
-// 	return ErrorFunctionHasNotReturned
-// }
+import (
+	"sync"
+	"time"
+)
 
+// VolumeFade fades the volume of a SunvoxChannel. It's a thin wrapper around a Tween (see SunvoxChannel.TweenVolume()),
+// and can be updated either manually through Update() or automatically through Start().
 type VolumeFade struct {
 	startVolume float32
 	endVolume   float32
-	duration    float32
 
 	Channel *SunvoxChannel
-	percent float32
+
+	Curve    Curve  // The curve used to fade the volume; if nil, the volume is faded linearly
+	OnFinish func() // Called once the fade finishes (if it isn't nil)
+
+	// If StopOnSilence is true, the channel is stopped once the fade finishes at a volume of 0. NewVolumeFade() sets it to true.
+	StopOnSilence bool
+
+	tween    *Tween
+	finished bool
 }
 
 func NewVolumeFade(start, end, seconds float32, channel *SunvoxChannel) *VolumeFade {
@@ -47,53 +33,79 @@ func NewVolumeFade(start, end, seconds float32, channel *SunvoxChannel) *VolumeF
 		end, _ = channel.Volume()
 	}
 
-	return &VolumeFade{
+	f := &VolumeFade{
 		startVolume: start,
 		endVolume:   end,
-		duration:    seconds,
 
-		Channel: channel,
+		Channel:       channel,
+		StopOnSilence: true,
 	}
+
+	f.tween = NewTween(float64(start), float64(end), secondsToDuration(seconds), func(value float64) {
+		if channel.IsValid() {
+			channel.SetVolume(float32(value))
+		}
+	})
+
+	f.Restart()
+
+	return f
 }
 
 func (f *VolumeFade) Restart() {
-	f.percent = 0
+	f.tween.reset()
+	f.finished = false
 }
 
-func (f *VolumeFade) Update(dt float32) (float32, bool) {
+// Start restarts the VolumeFade and has the engine update it automatically until it finishes, rather than having to call
+// Update() manually. Depending on the engine's update mode, this happens either on a background goroutine or whenever
+// SunvoxEngine.Update() is called (see SunvoxEngine.SetUpdateMode()).
+func (f *VolumeFade) Start() {
+	f.Restart()
+	engine.addTask(f)
+}
 
-	f.percent += dt / f.duration
+// Stop stops the engine from updating the VolumeFade automatically.
+func (f *VolumeFade) Stop() {
+	engine.removeTask(f)
+}
 
-	if f.percent > 1 {
-		f.percent = 1
-	}
+// IsRunning returns if the VolumeFade is being updated automatically by the engine.
+func (f *VolumeFade) IsRunning() bool {
+	return engine.hasTask(f)
+}
 
-	if f.percent < 0 {
-		f.percent = 0
-	}
+func (f *VolumeFade) step(dt float32) bool {
+	_, finished := f.Update(dt)
+	return finished
+}
 
-	targetVolume := f.startVolume + (f.percent * (f.endVolume - f.startVolume))
+func (f *VolumeFade) Update(dt float32) (float32, bool) {
 
-	if f.Channel.IsValid() {
-		f.Channel.SetVolume(targetVolume)
-	}
+	value, finished := f.tween.WithCurve(f.Curve).advance(secondsToDuration(dt))
 
-	if f.percent >= 1 && f.endVolume <= 0 {
-		f.Channel.Stop()
+	if finished && !f.finished {
+		f.finished = true
+		if f.StopOnSilence && f.endVolume <= 0 {
+			f.Channel.Stop()
+		}
+		if f.OnFinish != nil {
+			f.OnFinish()
+		}
 	}
 
-	return targetVolume, f.percent >= 1
+	return float32(value), finished
 }
 
+// ControllerFade fades a controller of a SunvoxModule. It's a thin wrapper around a Tween (see SunvoxModule.TweenController()),
+// and can be updated either manually through Update() or automatically through Start().
 type ControllerFade struct {
-	start    int
-	end      int
-	duration float32
-
 	Module     *SunvoxModule
 	Controller int
 
-	percent float32
+	Curve Curve // The curve used to fade the controller; if nil, the controller is faded linearly
+
+	tween *Tween
 }
 
 func NewControllerFade(start, end int, seconds float32, module *SunvoxModule, controller int) *ControllerFade {
@@ -108,43 +120,65 @@ func NewControllerFade(start, end int, seconds float32, module *SunvoxModule, co
 		end = v
 	}
 
-	return &ControllerFade{
-		start:    start,
-		end:      end,
-		duration: seconds,
-
+	f := &ControllerFade{
 		Module:     module,
 		Controller: controller,
 	}
+
+	f.tween = NewTween(float64(start), float64(end), secondsToDuration(seconds), func(value float64) {
+		if module.IsValid() {
+			module.setControllerValue(controller, int(value))
+		}
+	})
+
+	f.Restart()
+
+	return f
 }
 
 func (f *ControllerFade) Restart() {
-	f.percent = 0
+	f.tween.reset()
 }
 
-func (f *ControllerFade) Update(dt float32) (int, bool) {
-
-	f.percent += dt / f.duration
+// Start restarts the ControllerFade and has the engine update it automatically until it finishes, rather than having to call
+// Update() manually. Depending on the engine's update mode, this happens either on a background goroutine or whenever
+// SunvoxEngine.Update() is called (see SunvoxEngine.SetUpdateMode()).
+func (f *ControllerFade) Start() {
+	f.Restart()
+	engine.addTask(f)
+}
 
-	if f.percent > 1 {
-		f.percent = 1
-	}
+// Stop stops the engine from updating the ControllerFade automatically.
+func (f *ControllerFade) Stop() {
+	engine.removeTask(f)
+}
 
-	if f.percent < 0 {
-		f.percent = 0
-	}
+// IsRunning returns if the ControllerFade is being updated automatically by the engine.
+func (f *ControllerFade) IsRunning() bool {
+	return engine.hasTask(f)
+}
 
-	targetValue := int(float32(f.start) + (f.percent * (float32(f.end) - float32(f.start))))
+func (f *ControllerFade) step(dt float32) bool {
+	_, finished := f.Update(dt)
+	return finished
+}
 
-	if f.Module.IsValid() {
-		f.Module.SetControllerValue(f.Controller, targetValue)
-	}
+func (f *ControllerFade) Update(dt float32) (int, bool) {
+	value, finished := f.tween.WithCurve(f.Curve).advance(secondsToDuration(dt))
+	return int(value), finished
+}
 
-	return targetValue, f.percent >= 1
+// secondsToDuration converts a number of seconds to a time.Duration.
+func secondsToDuration(seconds float32) time.Duration {
+	return time.Duration(float64(seconds) * float64(time.Second))
 }
 
 // cache is used to cache some relevant properties (pattern line number, for example) so we don't have to call the sunvox function to get that function unless it's necessary.
-type cache map[int]map[string]any
+// Each SunvoxChannel has its own cache, as pattern indices are only unique within a project.
+type cache struct {
+	mutex sync.Mutex
+	data  map[int]map[string]any
+}
 
 func (c *cache) Get(index int, accessor string) any {
 
@@ -152,10 +186,10 @@ func (c *cache) Get(index int, accessor string) any {
 		return nil
 	}
 
-	if _, ok := (*c)[index]; !ok {
-		(*c)[index] = map[string]any{}
-	}
-	return (*c)[index][accessor]
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.data[index][accessor]
 }
 
 func (c *cache) Set(index int, accessor string, value any) {
@@ -164,13 +198,25 @@ func (c *cache) Set(index int, accessor string, value any) {
 		return
 	}
 
-	if _, ok := (*c)[index]; !ok {
-		(*c)[index] = map[string]any{}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.data == nil {
+		c.data = map[int]map[string]any{}
 	}
-	(*c)[index][accessor] = value
+
+	if _, ok := c.data[index]; !ok {
+		c.data[index] = map[string]any{}
+	}
+	c.data[index][accessor] = value
 }
 
-var patternCache = cache{}
+// Clear clears all cached data (for example, when a different project is loaded).
+func (c *cache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.data)
+}
 
 // When enabled, some data will be cached when retrieved. This is good for performance, but I'll need to either make it possible to disable / invalidate the cache, or invalidate
 // the cache when making some function calls, like modifying pattern size.